@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// Alert categories. Each maps to its own exit code so CI pipelines can
+// branch on the kind of failure.
+const (
+	alertLeak      = "leak"
+	alertRSSGrowth = "rss-growth"
+	alertCPU       = "cpu"
+	alertZombie    = "zombie"
+	alertOOM       = "oom"
+)
+
+// defaultExitCodes is the exit code used for each alert category unless
+// overridden with --exit-code.
+var defaultExitCodes = map[string]int{
+	alertLeak:      10,
+	alertRSSGrowth: 1, // --alert-rss-growth is documented as a plain pass/fail guardrail
+	alertCPU:       11,
+	alertZombie:    12,
+	alertOOM:       13,
+}
+
+// defaultExitPriority orders alert categories from most to least urgent.
+// When several categories fire, the exit code of the first one wins.
+var defaultExitPriority = []string{alertOOM, alertLeak, alertRSSGrowth, alertZombie, alertCPU}
+
+// alertSeverity is the severity reported for each alert category.
+var alertSeverity = map[string]string{
+	alertLeak:      "critical",
+	alertRSSGrowth: "warning",
+	alertCPU:       "warning",
+	alertZombie:    "warning",
+	alertOOM:       "critical",
+}
+
+// alert is a threshold violation found in the aggregated stats.
+type alert struct {
+	Process   string
+	Category  string
+	Metric    string
+	Value     float64
+	Threshold float64
+	Timestamp time.Time // latest sample the violation is based on
+}
+
+func (a alert) String() string {
+	// Some thresholds, such as --fail-on-zombies, fire once they are met.
+	verb := "exceeds"
+	if a.Value == a.Threshold {
+		verb = "reaches"
+	}
+	return fmt.Sprintf("%s: %s %.2f %s %.2f (%s)", a.Process, a.Metric, a.Value, verb, a.Threshold, a.Category)
+}
+
+// evaluateAlerts checks stats against the thresholds configured in opts and
+// returns the violations ordered by process name.
+func evaluateAlerts(stats map[string]parse.ProcessStats, opts options) []alert {
+	var alerts []alert
+	for name, stat := range stats {
+		if dist := distributionSamples(stat); opts.alertP95CPU > 0 && len(dist) > 0 {
+			if p95 := percentile(sampleValues(dist, sampleCPU), 95); p95 > opts.alertP95CPU {
+				alerts = append(alerts, alert{
+					Process:   name,
+					Category:  alertCPU,
+					Metric:    "p95 CPU (%)",
+					Value:     p95,
+					Threshold: opts.alertP95CPU,
+					Timestamp: stat.LatestTime,
+				})
+			}
+		}
+		if dist := distributionSamples(stat); opts.detectLeaks && len(dist) > 0 {
+			if slope, r2 := memoryTrend(rssPoints(sortedByTime(dist))); isLeaking(slope, r2, opts) {
+				alerts = append(alerts, alert{
+					Process:   name,
+					Category:  alertLeak,
+					Metric:    "RSS trend (MB/h)",
+					Value:     slope,
+					Threshold: opts.leakSlope,
+					Timestamp: stat.LatestTime,
+				})
+			}
+		}
+		if dist := distributionSamples(stat); opts.alertGrowth > 0 && len(dist) > 0 {
+			slope, r2 := memoryTrend(rssPoints(sortedByTime(dist)))
+			if slope > opts.alertGrowth && r2 >= opts.leakR2 {
+				alerts = append(alerts, alert{
+					Process:   name,
+					Category:  alertRSSGrowth,
+					Metric:    "RSS growth (MB/h)",
+					Value:     slope,
+					Threshold: opts.alertGrowth,
+					Timestamp: stat.LatestTime,
+				})
+			}
+		}
+	}
+	if zombies := zombieProcesses(stats); opts.failOnZombies > 0 && len(zombies) >= opts.failOnZombies {
+		for _, name := range zombies {
+			alerts = append(alerts, alert{
+				Process:   name,
+				Category:  alertZombie,
+				Metric:    "zombie processes",
+				Value:     float64(len(zombies)),
+				Threshold: float64(opts.failOnZombies),
+				Timestamp: stats[name].LatestTime,
+			})
+		}
+	}
+	if opts.totalMemory > 0 {
+		used := totalLatestRSS(stats)
+		if pct := used / opts.totalMemory * 100; pct >= opts.oomThreshold {
+			alerts = append(alerts, alert{
+				Process:   "system",
+				Category:  alertOOM,
+				Metric:    "memory used (%)",
+				Value:     pct,
+				Threshold: opts.oomThreshold,
+				Timestamp: latestTime(stats),
+			})
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Process < alerts[j].Process })
+	return alerts
+}
+
+// zombieProcesses returns the names of the processes whose latest state is
+// zombie, in sorted order.
+func zombieProcesses(stats map[string]parse.ProcessStats) []string {
+	var names []string
+	for name, stat := range stats {
+		if parse.StateCode(stat.State) == 'Z' {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// latestTime returns the most recent sample time across all processes.
+func latestTime(stats map[string]parse.ProcessStats) time.Time {
+	var latest time.Time
+	for _, stat := range stats {
+		if stat.LatestTime.After(latest) {
+			latest = stat.LatestTime
+		}
+	}
+	return latest
+}
+
+// alertRecord is the NDJSON form of an alert.
+type alertRecord struct {
+	Process   string    `json:"process"`
+	Category  string    `json:"category"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Timestamp time.Time `json:"timestamp"`
+	Severity  string    `json:"severity"`
+}
+
+// writeAlertsNDJSON writes one JSON object per alert, each on its own line.
+func writeAlertsNDJSON(w io.Writer, alerts []alert) error {
+	enc := json.NewEncoder(w)
+	for _, a := range alerts {
+		err := enc.Encode(alertRecord{
+			Process:   a.Process,
+			Category:  a.Category,
+			Metric:    a.Metric,
+			Value:     a.Value,
+			Threshold: a.Threshold,
+			Timestamp: a.Timestamp,
+			Severity:  alertSeverity[a.Category],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exitCodes is a repeatable category=code flag overlaying defaultExitCodes.
+type exitCodes map[string]int
+
+func (ec exitCodes) String() string {
+	parts := make([]string, 0, len(ec))
+	for category, code := range ec {
+		parts = append(parts, fmt.Sprintf("%s=%d", category, code))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (ec exitCodes) Set(value string) error {
+	category, code, ok := strings.Cut(value, "=")
+	if _, known := defaultExitCodes[category]; !ok || !known {
+		return fmt.Errorf("expected category=code with category one of %s, got %q", strings.Join(defaultExitPriority, ", "), value)
+	}
+	n, err := strconv.Atoi(code)
+	if err != nil || n < 1 || n > 125 {
+		return fmt.Errorf("invalid exit code %q, must be 1–125", code)
+	}
+	ec[category] = n
+	return nil
+}
+
+// parseExitPriority parses a comma-separated list of alert categories.
+// Categories left out keep their default relative order after the listed
+// ones.
+func parseExitPriority(value string) ([]string, error) {
+	var priority []string
+	seen := map[string]bool{}
+	for _, category := range strings.Split(value, ",") {
+		category = strings.TrimSpace(category)
+		if _, ok := defaultExitCodes[category]; !ok {
+			return nil, fmt.Errorf("unknown alert category %q", category)
+		}
+		if !seen[category] {
+			priority = append(priority, category)
+			seen[category] = true
+		}
+	}
+	for _, category := range defaultExitPriority {
+		if !seen[category] {
+			priority = append(priority, category)
+		}
+	}
+	return priority, nil
+}
+
+// exitCode returns the exit code for the highest-priority category among
+// alerts, or 0 when there are none.
+func exitCode(alerts []alert, codes exitCodes, priority []string) int {
+	if len(alerts) == 0 {
+		return 0
+	}
+	fired := map[string]bool{}
+	for _, a := range alerts {
+		fired[a.Category] = true
+	}
+	for _, category := range priority {
+		if !fired[category] {
+			continue
+		}
+		if code, ok := codes[category]; ok {
+			return code
+		}
+		return defaultExitCodes[category]
+	}
+	return 1
+}
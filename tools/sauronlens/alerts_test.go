@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// alertFixture returns the stats of a process growing 10 MB an hour at high
+// CPU, a flat idle one and a zombie.
+func alertFixture(t *testing.T) map[string]parse.ProcessStats {
+	t.Helper()
+	opts := testOptions()
+	opts.retainSamples = true
+	var lines []string
+	for h := range 6 {
+		at := fmt.Sprintf("2025-02-21T%02d:00:00Z", h)
+		lines = append(lines,
+			logLine(1, "leaky", "Running", 100+10*float64(h), 90, at),
+			logLine(2, "idle", "Sleeping (interruptible)", 20, 0.5, at),
+		)
+	}
+	lines = append(lines, logLine(3, "dead", "Zombie", 0, 0, "2025-02-21T05:00:00Z"))
+	return aggregate(t, opts, lines...)
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	stats := alertFixture(t)
+	opts := testOptions()
+	opts.alertGrowth = 5
+	opts.leakR2 = 0.8
+	opts.alertP95CPU = 80
+	opts.failOnZombies = 1
+
+	alerts := evaluateAlerts(stats, opts)
+	var got []string
+	for _, a := range alerts {
+		got = append(got, a.Process+"/"+a.Category)
+	}
+	want := []string{"dead/" + alertZombie, "leaky/" + alertCPU, "leaky/" + alertRSSGrowth}
+	if !slices.Equal(got, want) {
+		t.Fatalf("alerts = %v, want %v", got, want)
+	}
+	if code := exitCode(alerts, nil, defaultExitPriority); code != defaultExitCodes[alertRSSGrowth] {
+		t.Errorf("exit code %d, want the rss-growth code %d", code, defaultExitCodes[alertRSSGrowth])
+	}
+	if code := exitCode(alerts, exitCodes{alertCPU: 3}, []string{alertCPU, alertZombie}); code != 3 {
+		t.Errorf("exit code with cpu=3 first = %d, want 3", code)
+	}
+
+	clean := testOptions()
+	clean.alertGrowth = 20
+	clean.leakR2 = 0.8
+	clean.alertP95CPU = 95
+	clean.failOnZombies = 2
+	if alerts := evaluateAlerts(stats, clean); len(alerts) != 0 {
+		t.Errorf("clean run reported %v", alerts)
+	}
+	if code := exitCode(nil, nil, defaultExitPriority); code != 0 {
+		t.Errorf("exit code without alerts = %d", code)
+	}
+}
+
+func TestFailOnZombies(t *testing.T) {
+	stats := aggregate(t, testOptions(),
+		logLine(1, "a", "Zombie", 0, 0, "2025-02-21T12:00:00Z"),
+		logLine(2, "b", "Z", 0, 0, "2025-02-21T12:00:00Z"),
+		logLine(3, "c", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+	)
+
+	opts := testOptions()
+	opts.failOnZombies = 2
+	alerts := evaluateAlerts(stats, opts)
+	if len(alerts) != 2 || alerts[0].Process != "a" || alerts[1].Process != "b" {
+		t.Fatalf("--fail-on-zombies 2: alerts = %v, want a and b", alerts)
+	}
+	if got, want := alerts[0].String(), "a: zombie processes 2.00 reaches 2.00 (zombie)"; got != want {
+		t.Errorf("alert = %q, want %q", got, want)
+	}
+	if code := exitCode(alerts, nil, defaultExitPriority); code != defaultExitCodes[alertZombie] {
+		t.Errorf("exit code %d, want the zombie code %d", code, defaultExitCodes[alertZombie])
+	}
+
+	opts.failOnZombies = 3
+	if alerts := evaluateAlerts(stats, opts); len(alerts) != 0 {
+		t.Errorf("--fail-on-zombies 3: alerts = %v, want none", alerts)
+	}
+}
+
+func TestDetectLeaksExitCode(t *testing.T) {
+	stats := alertFixture(t)
+	opts := testOptions()
+	opts.detectLeaks = true
+	opts.leakSlope = 1
+	opts.leakR2 = 0.8
+
+	alerts := evaluateAlerts(stats, opts)
+	if len(alerts) != 1 || alerts[0].Process != "leaky" || alerts[0].Category != alertLeak {
+		t.Fatalf("alerts = %v, want one leak on leaky", alerts)
+	}
+	if code := exitCode(alerts, nil, defaultExitPriority); code != 10 {
+		t.Errorf("exit code %d, want 10", code)
+	}
+
+	// The leak outranks the rss-growth alert raised for the same trend.
+	opts.alertGrowth = 5
+	if code := exitCode(evaluateAlerts(stats, opts), nil, defaultExitPriority); code != 10 {
+		t.Errorf("exit code with --alert-rss-growth too = %d, want 10", code)
+	}
+}
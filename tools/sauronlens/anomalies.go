@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// anomalyMinSamples is the number of samples below which a process's
+// history is too short for z-scores to mean anything.
+const anomalyMinSamples = 5
+
+// latestAnomalies describes each latest reading of stat that lies more than
+// threshold standard deviations from the mean of the process's earlier
+// samples, e.g. "Latest CPU is 4.2σ above mean". The latest reading is left
+// out of the mean so an outlier does not dampen its own score.
+func latestAnomalies(stat parse.ProcessStats, threshold float64) []string {
+	if stat.Count < anomalyMinSamples {
+		return nil
+	}
+	metrics := []struct {
+		label  string
+		spread parse.Welford
+		latest float64
+	}{
+		{"CPU", stat.SpreadCPU, stat.LatestCPU},
+		{"RSS", stat.SpreadMemory, stat.LatestMemory},
+		{"PSS", stat.SpreadPSS, stat.LatestPSS},
+	}
+	var out []string
+	for _, m := range metrics {
+		z := m.spread.Without(m.latest).ZScore(m.latest)
+		if math.IsNaN(z) || math.Abs(z) <= threshold {
+			continue
+		}
+		direction := "above"
+		if z < 0 {
+			direction = "below"
+		}
+		out = append(out, fmt.Sprintf("Latest %s is %.1fσ %s mean", m.label, math.Abs(z), direction))
+	}
+	return out
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestLatestAnomalies(t *testing.T) {
+	cpu := []float64{10, 12, 11, 13, 10, 50}
+	rss := []float64{100, 101, 99, 100, 101, 100}
+	var lines []string
+	for i := range cpu {
+		lines = append(lines, logLine(1, "foo", "Running", rss[i], cpu[i], fmt.Sprintf("2025-02-21T12:%02d:00Z", i)))
+	}
+
+	// CPU ends far outside its history; RSS does not, and the constant PSS
+	// has no spread to score against.
+	stat := aggregate(t, testOptions(), lines...)["foo"]
+	want := []string{"Latest CPU is 29.8σ above mean"}
+	if got := latestAnomalies(stat, 3); !slices.Equal(got, want) {
+		t.Errorf("latestAnomalies = %q, want %q", got, want)
+	}
+
+	short := aggregate(t, testOptions(), lines[2:]...)["foo"]
+	if got := latestAnomalies(short, 3); got != nil {
+		t.Errorf("with %d samples: %q, want none", short.Count, got)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Supported --color values.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// ANSI escape sequences used to highlight report values.
+const (
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiReset  = "\x1b[0m"
+)
+
+// highlighter colors report values that cross a threshold. The zero value
+// never colors, so the thresholds can be checked without a terminal.
+type highlighter struct {
+	enabled    bool
+	cpuPercent float64 // CPU values above this are red
+	spikeRatio float64 // RSS peaks at least this many times the average are yellow
+}
+
+// cpu returns text, red when v exceeds the CPU threshold.
+func (h highlighter) cpu(v float64, text string) string {
+	if h.enabled && v > h.cpuPercent {
+		return ansiRed + text + ansiReset
+	}
+	return text
+}
+
+// spike returns text, yellow when peak is a spike relative to avg.
+func (h highlighter) spike(peak, avg float64, text string) string {
+	if h.enabled && avg > 0 && peak/avg >= h.spikeRatio {
+		return ansiYellow + text + ansiReset
+	}
+	return text
+}
+
+// useColor resolves a --color mode. auto colors only when stdout is a
+// terminal, and NO_COLOR disables color unless it is forced.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case colorAuto:
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		return isTerminal(os.Stdout), nil
+	}
+	return false, fmt.Errorf("unknown --color %q (have: auto, always, never)", mode)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlighter(t *testing.T) {
+	on := highlighter{enabled: true, cpuPercent: 80, spikeRatio: 2}
+	if got := on.cpu(90, "90%"); got != ansiRed+"90%"+ansiReset {
+		t.Errorf("cpu above threshold = %q, want red", got)
+	}
+	if got := on.cpu(80, "80%"); got != "80%" {
+		t.Errorf("cpu at threshold = %q, want plain", got)
+	}
+	if got := on.spike(20, 10, "20 MB"); got != ansiYellow+"20 MB"+ansiReset {
+		t.Errorf("spike = %q, want yellow", got)
+	}
+	if got := on.spike(15, 10, "15 MB"); got != "15 MB" {
+		t.Errorf("no spike = %q, want plain", got)
+	}
+}
+
+func TestNoEscapeCodesWhenColorDisabled(t *testing.T) {
+	stats := aggregate(t, testOptions(),
+		logLine(1, "foo", "Running", 1, 99, "2025-02-21T12:00:00Z"),
+		logLine(1, "foo", "Running", 100, 99, "2025-02-21T12:01:00Z"),
+	)
+	opts := testOptions()
+	opts.color = highlighter{enabled: false, cpuPercent: 10, spikeRatio: 1.5}
+	if out := textReport(t, stats, opts); strings.Contains(out, "\x1b") {
+		t.Errorf("report with color disabled contains escape codes:\n%q", out)
+	}
+	opts.color.enabled = true
+	if out := textReport(t, stats, opts); !strings.Contains(out, ansiRed) || !strings.Contains(out, ansiYellow) {
+		t.Error("report with color enabled has no highlights")
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	for mode, want := range map[string]bool{colorAuto: false, colorAlways: true, colorNever: false} {
+		if got, err := useColor(mode); err != nil || got != want {
+			t.Errorf("useColor(%s) with NO_COLOR = %v, %v; want %v", mode, got, err, want)
+		}
+	}
+	if _, err := useColor("sometimes"); err == nil {
+		t.Error("useColor accepted an unknown mode")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+)
+
+// Subcommand names.
+const (
+	cmdReport   = "report"
+	cmdDiff     = "diff"
+	cmdValidate = "validate"
+	cmdServe    = "serve"
+	cmdHelp     = "help"
+)
+
+// defaultServeAddr is the address the serve subcommand listens on unless
+// --serve is given.
+const defaultServeAddr = ":8080"
+
+// commands lists the subcommands with a one-line summary, in the order help
+// prints them.
+var commands = []struct{ name, summary string }{
+	{cmdReport, "aggregate logs and print per-process statistics (the default)"},
+	{cmdDiff, "compare the statistics of two logs: diff [flags] <old.log> <new.log>"},
+	{cmdValidate, "check that every line has exactly the Sauron fields, exiting 1 if not"},
+	{cmdServe, "aggregate logs and serve the statistics as JSON on " + defaultServeAddr + " until interrupted"},
+	{cmdHelp, "list the subcommands"},
+}
+
+// parseFlags read and parse log lines; every subcommand that reads files
+// takes them.
+var parseFlags = []string{
+	"field-sep", "kv-sep", "time-layout", "max-errors", "max-line-bytes",
+	"limit-lines", "quiet", "workers", "errors-out", "input-format",
+}
+
+// aggregateFlags decide which entries are aggregated, and under which key.
+var aggregateFlags = []string{
+	"host", "no-negative", "lenient", "regex", "cpu-fraction", "reorder-window", "dedup",
+	"flatten", "by-pid", "track-by-pid-then-name", "name-pattern", "include-file",
+	"exclude-file", "filter", "process", "since", "until",
+}
+
+// commandFlags lists the flags of the subcommands that take fewer than
+// report, which takes every flag.
+var commandFlags = map[string][]string{
+	cmdDiff: slices.Concat(parseFlags, aggregateFlags, []string{"precision", "rounding", "output"}),
+	cmdValidate: slices.Concat(parseFlags, []string{
+		"recursive", "strict", "listen", "keep-listening", "listen-unix",
+	}),
+}
+
+// serveExcludedFlags are the report flags serve does not take: they select
+// a different mode that would never reach the server.
+var serveExcludedFlags = []string{"validate", "watch", "trace", "get", "listen", "keep-listening", "listen-unix"}
+
+// acceptsFlag reports whether the subcommand name takes the flag.
+func acceptsFlag(name, flagName string) bool {
+	switch name {
+	case cmdReport:
+		return true
+	case cmdServe:
+		return !slices.Contains(serveExcludedFlags, flagName)
+	}
+	return slices.Contains(commandFlags[name], flagName)
+}
+
+// commandFlagSet returns a flag set for the subcommand name holding the
+// flags of defs it accepts, so a flag the subcommand would ignore is a usage
+// error. The flags share their values with defs.
+func commandFlagSet(name string, defs *flag.FlagSet) *flag.FlagSet {
+	fs := flag.NewFlagSet("sauronlens "+name, flag.ExitOnError)
+	defs.VisitAll(func(f *flag.Flag) {
+		if acceptsFlag(name, f.Name) {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	return fs
+}
+
+// dispatch splits the command line into a subcommand and its arguments. A
+// command line that does not start with one is a report, so invocations
+// from before the subcommands keep working.
+func dispatch(args []string) (string, []string) {
+	if len(args) > 0 {
+		for _, c := range commands {
+			if args[0] == c.name {
+				return c.name, args[1:]
+			}
+		}
+	}
+	return cmdReport, args
+}
+
+// run runs the subcommand selected by args and returns the process exit
+// code.
+func run(args []string) int {
+	name, args := dispatch(args)
+	if name == cmdHelp {
+		printHelp(os.Stdout)
+		return 0
+	}
+	return runCommand(name, args)
+}
+
+// printHelp lists the subcommands.
+func printHelp(out io.Writer) {
+	_, _ = fmt.Fprintln(out, "Usage: sauronlens [command] [flags] [log_file...]")
+	_, _ = fmt.Fprintln(out, "\nCommands:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\n", c.name, c.summary)
+	}
+	_ = w.Flush()
+	_, _ = fmt.Fprintln(out, "\nRun 'sauronlens <command> -h' for the flags of a command.")
+}
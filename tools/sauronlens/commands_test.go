@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantName string
+		wantArgs []string
+	}{
+		{nil, cmdReport, nil},
+		{[]string{"process.log"}, cmdReport, []string{"process.log"}},
+		{[]string{"--top", "3", "process.log"}, cmdReport, []string{"--top", "3", "process.log"}},
+		{[]string{"report", "--json", "a.log"}, cmdReport, []string{"--json", "a.log"}},
+		{[]string{"diff", "old.log", "new.log"}, cmdDiff, []string{"old.log", "new.log"}},
+		{[]string{"validate", "--strict", "a.log"}, cmdValidate, []string{"--strict", "a.log"}},
+		{[]string{"serve", "--serve", ":9090"}, cmdServe, []string{"--serve", ":9090"}},
+		{[]string{"help"}, cmdHelp, []string{}},
+		// Only the first argument selects a subcommand.
+		{[]string{"a.log", "diff"}, cmdReport, []string{"a.log", "diff"}},
+	}
+	for _, tt := range tests {
+		name, args := dispatch(tt.args)
+		if name != tt.wantName || !slices.Equal(args, tt.wantArgs) {
+			t.Errorf("dispatch(%q) = %s %q, want %s %q", tt.args, name, args, tt.wantName, tt.wantArgs)
+		}
+	}
+}
+
+func TestCommandFlagSet(t *testing.T) {
+	defs := flag.NewFlagSet("sauronlens", flag.ContinueOnError)
+	for _, name := range []string{"precision", "sparkline", "strict", "watch", "field-sep"} {
+		defs.Bool(name, false, "")
+	}
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{cmdReport, []string{"field-sep", "precision", "sparkline", "strict", "watch"}},
+		{cmdDiff, []string{"field-sep", "precision"}},
+		{cmdValidate, []string{"field-sep", "strict"}},
+		{cmdServe, []string{"field-sep", "precision", "sparkline", "strict"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		commandFlagSet(tt.command, defs).VisitAll(func(f *flag.Flag) { got = append(got, f.Name) })
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s flags = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestRunSelectsSubcommand(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeLog(t, dir, "valid.log", "PID: 1 | Name: foo | State: Running | Threads: 2 | RSS (MB): 10 | VSZ (MB): 20 | PSS (MB): 5 | CPU (%): 1 | Uptime (sec): 100 | Last Checked: 2025-02-21T12:00:00Z")
+	invalid := writeLog(t, dir, "invalid.log", "Name: foo | State: Running | RSS (MB): 10 | VSZ (MB): 20 | PSS (MB): 5 | CPU (%): 1 | Last Checked: 2025-02-21T12:00:00Z")
+	for _, tt := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"help"}, 0},
+		{[]string{"validate", valid}, 0},
+		{[]string{"validate", invalid}, 1},
+		{[]string{"--listen=tcp://127.0.0.1:0", "--listen-unix=" + filepath.Join(dir, "sock")}, 1},
+	} {
+		if code := run(tt.args); code != tt.want {
+			t.Errorf("run(%q) = %d, want %d", tt.args, code, tt.want)
+		}
+	}
+}
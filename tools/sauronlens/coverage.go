@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// logField describes one field of the default log format.
+type logField struct {
+	key   string              // key before the key/value separator
+	valid func(v string) bool // reports whether the value is usable; nil for the timestamp
+}
+
+func isInt(v string) bool {
+	_, err := strconv.Atoi(v)
+	return err == nil
+}
+
+func isFloat(v string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	return err == nil
+}
+
+func isNonEmpty(v string) bool { return v != "" }
+
+// logFields lists the fields of the default log format.
+var logFields = []logField{
+	{"PID", isInt},
+	{"Name", isNonEmpty},
+	{"State", isNonEmpty},
+	{"Threads", isInt},
+	{"RSS (MB)", isFloat},
+	{"VSZ (MB)", isFloat},
+	{"PSS (MB)", isFloat},
+	{"CPU (%)", isFloat},
+	{"Uptime (sec)", isFloat},
+	{"Last Checked", nil}, // parsed like ingestion does, see fieldCoverage.times
+}
+
+// fieldCoverage counts, per field of the default log format, how many lines
+// carried a valid value. Unlike parse.ParseLogEntry it checks every field of a
+// line independently, so one bad field does not hide the others.
+type fieldCoverage struct {
+	format parse.Format
+	times  *parse.TimeParser // accepts the layouts ingestion accepts, honouring --time-layout
+	lines  int
+	valid  []int // indexed like logFields
+}
+
+func newFieldCoverage(format parse.Format) *fieldCoverage {
+	return &fieldCoverage{
+		format: format,
+		times:  &parse.TimeParser{Layout: format.TimeLayout},
+		valid:  make([]int, len(logFields)),
+	}
+}
+
+// add records the fields of a single log line.
+func (fc *fieldCoverage) add(line string) {
+	fc.lines++
+	fields := fc.format.Fields(line)
+	for i, f := range logFields {
+		v, ok := fields[f.key]
+		if !ok {
+			continue
+		}
+		if f.valid == nil {
+			_, err := fc.times.Parse(v)
+			ok = err == nil
+		} else {
+			ok = f.valid(v)
+		}
+		if ok {
+			fc.valid[i]++
+		}
+	}
+}
+
+// print writes the share of lines with a valid value for each field.
+func (fc *fieldCoverage) print(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Field coverage (%d lines):\n", fc.lines)
+	for i, f := range logFields {
+		pct := 0.0
+		if fc.lines > 0 {
+			pct = float64(fc.valid[i]) / float64(fc.lines) * 100
+		}
+		_, _ = fmt.Fprintf(w, "  %s:\t%.1f%%\n", f.key, pct)
+	}
+	_ = w.Flush()
+}
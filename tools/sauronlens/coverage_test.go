@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// timestampCoverage returns how many of lines fieldCoverage counts as having
+// a valid Last Checked under format.
+func timestampCoverage(format parse.Format, lines ...string) int {
+	fc := newFieldCoverage(format)
+	for _, line := range lines {
+		fc.add(line)
+	}
+	for i, f := range logFields {
+		if f.key == "Last Checked" {
+			return fc.valid[i]
+		}
+	}
+	return -1
+}
+
+func TestFieldCoverageTimestampLayouts(t *testing.T) {
+	lines := []string{
+		"Name: a | Last Checked: 2025-02-21T12:41:52Z",
+		"Name: a | Last Checked: 2025-02-21 12:41:52",
+		"Name: a | Last Checked: 2025-02-21T12:41:52.346+02:00",
+		"Name: a | Last Checked: yesterday",
+	}
+	if got := timestampCoverage(parse.DefaultFormat, lines...); got != 3 {
+		t.Errorf("default layouts: %d valid timestamps, want 3", got)
+	}
+
+	custom := parse.DefaultFormat
+	custom.TimeLayout = "02/01/2006 15:04"
+	if got := timestampCoverage(custom, "Name: a | Last Checked: 21/02/2025 12:41", lines[0]); got != 1 {
+		t.Errorf("--time-layout: %d valid timestamps, want only the one in that layout", got)
+	}
+}
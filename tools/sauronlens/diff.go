@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// How a process differs between two runs.
+const (
+	diffChanged = "changed"
+	diffAdded   = "added"
+	diffRemoved = "removed"
+)
+
+// delta is a metric's value in the old and the new run.
+type delta struct {
+	Old, New float64
+}
+
+// Abs returns the absolute change.
+func (d delta) Abs() float64 { return d.New - d.Old }
+
+// Pct returns the change relative to the old value, or NaN when that is 0.
+func (d delta) Pct() float64 {
+	if d.Old == 0 {
+		return math.NaN()
+	}
+	return (d.New - d.Old) / d.Old * 100
+}
+
+// processDiff compares one process across two runs. For added and removed
+// processes only the side that has the process is filled in.
+type processDiff struct {
+	Name      string
+	Status    string // diffChanged, diffAdded or diffRemoved
+	AvgCPU    delta
+	LatestCPU delta
+	AvgRSS    delta
+	LatestRSS delta
+}
+
+// diffStats compares the stats of two runs, ordered by process name.
+func diffStats(oldStats, newStats map[string]parse.ProcessStats) []processDiff {
+	names := make(map[string]bool)
+	for name := range oldStats {
+		names[name] = true
+	}
+	for name := range newStats {
+		names[name] = true
+	}
+
+	diffs := make([]processDiff, 0, len(names))
+	for name := range names {
+		o, inOld := oldStats[name]
+		n, inNew := newStats[name]
+		d := processDiff{Name: name, Status: diffChanged}
+		switch {
+		case !inOld:
+			d.Status = diffAdded
+		case !inNew:
+			d.Status = diffRemoved
+		}
+		if inOld {
+			d.AvgCPU.Old, d.LatestCPU.Old = o.TotalCPU/float64(o.Count), o.LatestCPU
+			d.AvgRSS.Old, d.LatestRSS.Old = o.TotalMemory/float64(o.Count), o.LatestMemory
+		}
+		if inNew {
+			d.AvgCPU.New, d.LatestCPU.New = n.TotalCPU/float64(n.Count), n.LatestCPU
+			d.AvgRSS.New, d.LatestRSS.New = n.TotalMemory/float64(n.Count), n.LatestMemory
+		}
+		diffs = append(diffs, d)
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
+
+// printDiff writes diffs in the layout of the text report. Changed processes
+// show each metric as old -> new with the absolute and percent change.
+func printDiff(out io.Writer, diffs []processDiff, precision int, rounding string) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(w, "Process %s: %s\n", d.Name, d.Status)
+		rows := []struct {
+			label string
+			unit  string
+			value delta
+		}{
+			{"Avg CPU Usage:", "%", d.AvgCPU},
+			{"Latest CPU Usage:", "%", d.LatestCPU},
+			{"Avg RSS (MB):", " MB", d.AvgRSS},
+			{"Latest RSS (MB):", " MB", d.LatestRSS},
+		}
+		for _, r := range rows {
+			switch d.Status {
+			case diffAdded:
+				_, _ = fmt.Fprintf(w, "  %-22s\t%s%s\n", r.label, formatFloat(r.value.New, precision, rounding), r.unit)
+			case diffRemoved:
+				_, _ = fmt.Fprintf(w, "  %-22s\t%s%s\n", r.label, formatFloat(r.value.Old, precision, rounding), r.unit)
+			default:
+				pct := "n/a"
+				if p := r.value.Pct(); !math.IsNaN(p) {
+					pct = fmt.Sprintf("%+.1f%%", p)
+				}
+				abs := r.value.Abs()
+				_, _ = fmt.Fprintf(w, "  %-22s\t%s%s -> %s%s (%s, %s)\n", r.label, formatFloat(r.value.Old, precision, rounding), r.unit,
+					formatFloat(r.value.New, precision, rounding), r.unit, signed(abs, formatFloat(abs, precision, rounding)), pct)
+			}
+		}
+	}
+	_ = w.Flush()
+}
+
+// runDiff aggregates two log files separately and prints how every process
+// changed between them.
+func runDiff(args []string, opts options) int {
+	if len(args) != 2 {
+		fmt.Println("Usage: sauronlens diff [flags] <old.log> <new.log>")
+		return 1
+	}
+	var stats [2]map[string]parse.ProcessStats
+	for i, path := range args {
+		s, err := processFiles([]string{path}, opts, true)
+		if err != nil {
+			fmt.Println("Error processing logs:", err)
+			return 1
+		}
+		stats[i] = s
+	}
+	printDiff(opts.out, diffStats(stats[0], stats[1]), opts.precision, opts.rounding)
+	return 0
+}
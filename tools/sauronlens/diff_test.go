@@ -0,0 +1,35 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestDiffStats(t *testing.T) {
+	oldStats := map[string]parse.ProcessStats{
+		"httpd": {Count: 2, TotalCPU: 4, LatestCPU: 1, TotalMemory: 20, LatestMemory: 12},
+		"gone":  {Count: 1, TotalCPU: 3, LatestCPU: 3, TotalMemory: 5, LatestMemory: 5},
+	}
+	newStats := map[string]parse.ProcessStats{
+		"httpd": {Count: 2, TotalCPU: 6, LatestCPU: 4, TotalMemory: 30, LatestMemory: 18},
+		"fresh": {Count: 1, TotalCPU: 1, LatestCPU: 1, TotalMemory: 8, LatestMemory: 8},
+	}
+	want := []processDiff{
+		{Name: "fresh", Status: diffAdded, AvgCPU: delta{0, 1}, LatestCPU: delta{0, 1}, AvgRSS: delta{0, 8}, LatestRSS: delta{0, 8}},
+		{Name: "gone", Status: diffRemoved, AvgCPU: delta{3, 0}, LatestCPU: delta{3, 0}, AvgRSS: delta{5, 0}, LatestRSS: delta{5, 0}},
+		{Name: "httpd", Status: diffChanged, AvgCPU: delta{2, 3}, LatestCPU: delta{1, 4}, AvgRSS: delta{10, 15}, LatestRSS: delta{12, 18}},
+	}
+	got := diffStats(oldStats, newStats)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diffStats =\n%+v\nwant\n%+v", got, want)
+	}
+	if rss := got[2].AvgRSS; rss.Abs() != 5 || rss.Pct() != 50 {
+		t.Errorf("httpd avg RSS change %v (%v%%), want 5 (50%%)", rss.Abs(), rss.Pct())
+	}
+	if pct := got[0].AvgRSS.Pct(); !math.IsNaN(pct) {
+		t.Errorf("added process percent change = %v, want NaN", pct)
+	}
+}
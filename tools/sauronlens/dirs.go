@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// logFileName matches the files read from a directory argument: Sauron's
+// log and its rotations (process.log, process.log1, ...), optionally
+// gzipped.
+var logFileName = regexp.MustCompile(`\.log(\.?\d+)?(\.gz)?$`)
+
+// expandPaths replaces each directory in paths with the log files inside it,
+// oldest first by modification time so rotated logs are read in the order
+// they were written. Subdirectories are only searched when recursive is set.
+// Other files in a directory are skipped with a notice on stderr; files
+// named explicitly are kept as they are.
+func expandPaths(paths []string, recursive bool) ([]string, error) {
+	var out []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			// Missing files are reported when they are read.
+			out = append(out, path)
+			continue
+		}
+		files, err := logFilesIn(path, recursive)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			fmt.Fprintf(os.Stderr, "Notice: no log files in %s\n", path)
+		}
+		out = append(out, files...)
+	}
+	return out, nil
+}
+
+// logFilesIn returns the log files under dir, oldest first.
+func logFilesIn(dir string, recursive bool) ([]string, error) {
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !logFileName.MatchString(d.Name()) {
+			fmt.Fprintf(os.Stderr, "Notice: skipping %s, not a log file\n", path)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, logFile{path, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].path < files[j].path
+	})
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpandPathsReadsDirectoryOldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	current := writeLog(t, dir, "process.log", logLine(1, "foo", "Running", 20, 1, "2025-02-21T12:01:00Z"))
+	rotated := writeLog(t, dir, "process.log1", logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"))
+	writeLog(t, dir, "notes.txt", "not a log")
+	if err := os.Mkdir(filepath.Join(dir, "old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	nested := writeLog(t, filepath.Join(dir, "old"), "process.log2", logLine(1, "foo", "Running", 5, 1, "2025-02-21T11:59:00Z"))
+
+	now := time.Now()
+	for i, path := range []string{nested, rotated, current} {
+		mtime := now.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := expandPaths([]string{dir}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{rotated, current}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expandPaths = %q, want %q", got, want)
+	}
+
+	got, err = expandPaths([]string{dir}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{nested, rotated, current}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expandPaths recursive = %q, want %q", got, want)
+	}
+
+	stats, err := processFiles(got, testOptions(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if foo := stats["foo"]; foo.Count != 3 || foo.LatestMemory != 20 {
+		t.Errorf("foo count %d, latest RSS %v; want 3 and 20", foo.Count, foo.LatestMemory)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// event is a labelled point in time, such as a deployment, recorded
+// alongside a capture.
+type event struct {
+	Time  time.Time
+	Label string
+}
+
+// loadEvents reads timestamp,label rows from a CSV file. Timestamps are
+// RFC3339; a first row that does not parse is treated as a header.
+func loadEvents(path string) ([]event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+
+	var events []event
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ts, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(record[0]))
+		if err != nil {
+			if row == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("row %d: invalid timestamp: %v", row, err)
+		}
+		events = append(events, event{Time: ts, Label: record[1]})
+	}
+}
+
+// nearestEvent returns the event closest to t, provided it lies within
+// window of it.
+func nearestEvent(events []event, t time.Time, window time.Duration) (event, bool) {
+	var best event
+	found := false
+	for _, e := range events {
+		d := absDuration(e.Time.Sub(t))
+		if d <= window && (!found || d < absDuration(best.Time.Sub(t))) {
+			best, found = e, true
+		}
+	}
+	return best, found
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// peakEventNote describes the event nearest to a peak recorded at t, or
+// returns "" when there is none.
+func peakEventNote(events []event, t time.Time, window time.Duration) string {
+	e, ok := nearestEvent(events, t, window)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("at %s — near '%s' event", t.Format("15:04:05"), e.Label)
+}
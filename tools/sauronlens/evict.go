@@ -0,0 +1,67 @@
+package main
+
+import (
+	"container/list"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// keyLimiter bounds the number of processes held in the stats map for
+// --max-keys. Once there are more, the least recently updated process is
+// reported and evicted, so the report streams out as the input is read
+// instead of growing with the number of distinct keys.
+//
+// Eviction trades accuracy for memory: a process that appears again after
+// being evicted starts from empty stats and is reported a second time,
+// covering only its later samples, and the summary and alerts only see the
+// processes still held when the input ends.
+type keyLimiter struct {
+	max     int
+	order   *list.List // keys, most recently updated first
+	elems   map[string]*list.Element
+	Evicted int
+}
+
+func newKeyLimiter(max int) *keyLimiter {
+	return &keyLimiter{max: max, order: list.New(), elems: make(map[string]*list.Element)}
+}
+
+// touch records that key was just updated in stats, then evicts the least
+// recently updated keys beyond the limit, passing each to flush first.
+func (l *keyLimiter) touch(stats map[string]parse.ProcessStats, key string, flush func(string, parse.ProcessStats) error) error {
+	if e, ok := l.elems[key]; ok {
+		l.order.MoveToFront(e)
+	} else {
+		l.elems[key] = l.order.PushFront(key)
+	}
+	for l.order.Len() > l.max {
+		oldest := l.order.Remove(l.order.Back()).(string)
+		delete(l.elems, oldest)
+		stat, ok := stats[oldest]
+		if !ok {
+			// Already dropped by whoever owns stats.
+			continue
+		}
+		delete(stats, oldest)
+		l.Evicted++
+		if err := flush(oldest, stat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushEvicted reports a process evicted by --max-keys in the configured
+// format, without the summary footer.
+func flushEvicted(opts options) func(string, parse.ProcessStats) error {
+	return func(key string, stat parse.ProcessStats) error {
+		one := map[string]parse.ProcessStats{key: stat}
+		finalizeStats(one)
+		if opts.format == formatTemplate {
+			return printStatsTemplate(opts.out, one, opts.sortBy, opts.template)
+		}
+		opts.noSummary = true
+		printStats(opts.out, one, opts)
+		return nil
+	}
+}
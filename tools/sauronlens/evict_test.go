@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestKeyLimiterEvictsLeastRecentlyUpdated(t *testing.T) {
+	var evicted []string
+	flush := func(key string, stat parse.ProcessStats) error {
+		evicted = append(evicted, key)
+		return nil
+	}
+	stats := map[string]parse.ProcessStats{}
+	l := newKeyLimiter(2)
+	for _, key := range []string{"a", "b", "a", "c", "d"} {
+		stats[key] = parse.ProcessStats{Count: 1}
+		if err := l.touch(stats, key, flush); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"b", "a"}; !slices.Equal(evicted, want) || l.Evicted != 2 {
+		t.Errorf("evicted %v (%d), want %v", evicted, l.Evicted, want)
+	}
+	if names := sortedNames(stats, sortName); !slices.Equal(names, []string{"c", "d"}) {
+		t.Errorf("held %v, want [c d]", names)
+	}
+}
+
+func TestMaxKeysReportsEvictedProcesses(t *testing.T) {
+	var out bytes.Buffer
+	opts := testOptions()
+	opts.out = &out
+	opts.keys = newKeyLimiter(1)
+	stats := aggregate(t, opts,
+		logLine(1, "first", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		logLine(2, "second", "Running", 20, 1, "2025-02-21T12:01:00Z"),
+	)
+	if _, ok := stats["second"]; !ok || len(stats) != 1 {
+		t.Errorf("held %v, want only second", sortedNames(stats, sortName))
+	}
+	if report := out.String(); !strings.Contains(report, "Process first:") || strings.Contains(report, "Process second:") {
+		t.Errorf("evicted report:\n%s\nwant only first", report)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// followPoll is how often a followed file is checked for new data once all
+// of it has been read.
+const followPoll = 500 * time.Millisecond
+
+// follow aggregates the log file at path into live like tail -f: it reads
+// the existing content, then keeps consuming appended lines and reprints the
+// report every interval in which something changed. It returns the final
+// stats once the process receives SIGINT or SIGTERM.
+func follow(path string, interval time.Duration, opts options, live *liveStats) (map[string]parse.ProcessStats, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	lines := make(chan string, 256)
+	errCh := make(chan error, 1)
+	go func() { errCh <- followFile(ctx, path, followPoll, opts.maxLineBytes, lines) }()
+
+	opts.source = path
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lineNo, dirty := 0, false
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if err := <-errCh; err != nil {
+					return nil, err
+				}
+				return live.snapshot(), nil
+			}
+			lineNo++
+			err := live.update(func(stats map[string]parse.ProcessStats) error {
+				return aggregateLine(stats, line, lineNo, opts)
+			})
+			if err != nil {
+				return nil, err
+			}
+			dirty = true
+		case <-ticker.C:
+			if !dirty || opts.ndjson != nil {
+				continue
+			}
+			snapshot := live.snapshot()
+			_, _ = fmt.Fprintf(opts.out, "=== %s ===\n", time.Now().Format("2006-01-02 15:04:05"))
+			printStats(opts.out, snapshot, opts)
+			dirty = false
+		}
+	}
+}
+
+// sampleRecord is the --ndjson form of one aggregated sample.
+type sampleRecord struct {
+	Name      string    `json:"name"`
+	PID       int       `json:"pid"`
+	Timestamp time.Time `json:"timestamp"`
+	State     string    `json:"state"`
+	CPU       float64   `json:"cpu"`
+	RSS       float64   `json:"rss_mb"`
+	PSS       float64   `json:"pss_mb"`
+}
+
+// writeSampleNDJSON writes entry to w as a single JSON line. Each record is
+// one Write, so an unbuffered w passes it on as soon as it is read.
+func writeSampleNDJSON(w io.Writer, entry *parse.LogEntry) error {
+	return json.NewEncoder(w).Encode(sampleRecord{
+		Name:      entry.Name,
+		PID:       entry.PID,
+		Timestamp: entry.Timestamp,
+		State:     entry.State,
+		CPU:       entry.CPU,
+		RSS:       entry.Memory,
+		PSS:       entry.PSS,
+	})
+}
+
+// followFile sends every complete line of the file at path to lines, then
+// polls for appended data until ctx is done, closing lines on return. If the
+// file is truncated or replaced by log rotation, it is reopened and read from
+// the start. Like boundedScanner, it keeps at most maxLine+1 bytes of a line,
+// so a runaway line is rejected by parseLine instead of growing memory.
+func followFile(ctx context.Context, path string, poll time.Duration, maxLine int, lines chan<- string) error {
+	defer close(lines)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	var partial []byte // the start of a line, awaiting its newline
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		offset += int64(len(chunk))
+		if err == nil {
+			line := string(appendBounded(partial, bytes.TrimSuffix(chunk, []byte("\n")), maxLine))
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return nil
+			}
+			partial = partial[:0]
+			continue
+		}
+		partial = appendBounded(partial, chunk, maxLine)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(poll):
+		}
+
+		rotated, err := wasRotated(file, path, offset)
+		if os.IsNotExist(err) {
+			// Rotated away and not yet recreated.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if rotated {
+			next, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_ = file.Close()
+			file = next
+			reader.Reset(file)
+			offset, partial = 0, partial[:0]
+		}
+	}
+}
+
+// wasRotated reports whether the file at path is no longer the open file, or
+// has been truncated below the offset read so far.
+func wasRotated(file *os.File, path string, offset int64) (bool, error) {
+	current, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	open, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return !os.SameFile(open, current) || current.Size() < offset, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// appendFile appends data to the file at path.
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// nextLine waits for the next followed line.
+func nextLine(t *testing.T, lines <-chan string) string {
+	t.Helper()
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a followed line")
+		return ""
+	}
+}
+
+func TestFollowFileAppendsAndRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "process.log")
+	first := logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z")
+	if err := os.WriteFile(path, []byte(first+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan string)
+	errCh := make(chan error, 1)
+	go func() { errCh <- followFile(ctx, path, 10*time.Millisecond, bufio.MaxScanTokenSize, lines) }()
+
+	stats := map[string]parse.ProcessStats{}
+	merge := func(line string) {
+		if err := aggregateLine(stats, line, 0, testOptions()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	merge(nextLine(t, lines))
+
+	// An append arriving in two writes is only sent once its newline is.
+	second := logLine(1, "foo", "Running", 20, 1, "2025-02-21T12:01:00Z")
+	appendFile(t, path, second[:20])
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, second[20:]+"\n")
+	if line := nextLine(t, lines); line != second {
+		t.Fatalf("followed %q, want %q", line, second)
+	}
+	merge(second)
+	if got := stats["foo"].Count; got != 2 {
+		t.Fatalf("count after append = %d, want 2", got)
+	}
+
+	// Truncation, as by copytruncate rotation, restarts from the top.
+	third := logLine(1, "foo", "Running", 5, 1, "2025-02-21T12:02:00Z")
+	if err := os.WriteFile(path, []byte(third+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	merge(nextLine(t, lines))
+	if foo := stats["foo"]; foo.Count != 3 || foo.LatestMemory != 5 {
+		t.Errorf("after rotation: count %d, latest RSS %v; want 3 and 5", foo.Count, foo.LatestMemory)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("followFile: %v", err)
+	}
+}
+
+// writeCounter counts the Write calls it receives.
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (wc *writeCounter) Write(p []byte) (int, error) {
+	wc.writes++
+	return wc.Buffer.Write(p)
+}
+
+func TestNDJSONSamples(t *testing.T) {
+	var out writeCounter
+	opts := testOptions()
+	opts.ndjson = &out
+	aggregate(t, opts,
+		logLine(8770, "httpd", "Running", 10.5, 1.25, "2025-02-21T12:41:52.346Z"),
+		"garbage",
+		logLine(5998, "mdnsd", "Sleeping (interruptible)", 4.5, 0, "2025-02-21T12:41:53Z"),
+	)
+	const want = `{"name":"httpd","pid":8770,"timestamp":"2025-02-21T12:41:52.346Z","state":"Running","cpu":1.25,"rss_mb":10.5,"pss_mb":5}
+{"name":"mdnsd","pid":5998,"timestamp":"2025-02-21T12:41:53Z","state":"Sleeping (interruptible)","cpu":0,"rss_mb":4.5,"pss_mb":5}
+`
+	if got := out.String(); got != want {
+		t.Errorf("NDJSON:\n%s\nwant:\n%s", got, want)
+	}
+	// One Write per record, so an unbuffered stdout flushes each at once.
+	if out.writes != 2 {
+		t.Errorf("%d writes for 2 records", out.writes)
+	}
+}
+
+func TestFollowFileBoundsLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "process.log")
+	first := logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z")
+	if err := os.WriteFile(path, []byte(first+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string)
+	errCh := make(chan error, 1)
+	go func() { errCh <- followFile(ctx, path, 10*time.Millisecond, 1024, lines) }()
+	if line := nextLine(t, lines); line != first {
+		t.Fatalf("followed %q, want %q", line, first)
+	}
+
+	// A runaway line written without a newline is held to 1025 bytes.
+	for range 8 {
+		appendFile(t, path, strings.Repeat("x", 16<<10))
+		time.Sleep(20 * time.Millisecond)
+	}
+	last := logLine(1, "foo", "Running", 20, 1, "2025-02-21T12:01:00Z")
+	appendFile(t, path, "\n"+last+"\n")
+
+	opts := testOptions()
+	opts.maxLineBytes = 1024
+	opts.skips = &skipReport{}
+	stats := map[string]parse.ProcessStats{}
+	runaway := nextLine(t, lines)
+	if len(runaway) != 1025 {
+		t.Errorf("runaway line is %d bytes, want 1025", len(runaway))
+	}
+	for i, line := range []string{first, runaway, nextLine(t, lines)} {
+		if err := aggregateLine(stats, line, i+1, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if opts.skips.Skipped != 1 || !strings.Contains(opts.skips.First[0], "line 2: line longer than --max-line-bytes 1024") {
+		t.Errorf("skips = %d %q, want line 2 too long", opts.skips.Skipped, opts.skips.First)
+	}
+	if foo := stats["foo"]; foo.Count != 2 || foo.LatestMemory != 20 {
+		t.Errorf("foo count %d, latest RSS %v; want 2 and 20", foo.Count, foo.LatestMemory)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Errorf("followFile: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// Supported report formats.
+const (
+	formatText       = "text"
+	formatJSON       = "json"
+	formatCSV        = "csv"
+	formatPrometheus = "prometheus"
+	formatMarkdown   = "markdown"
+	formatCompact    = "compact"
+	formatTemplate   = "template"
+)
+
+// defaultPrecision is the number of decimals metrics are reported with
+// unless --precision says otherwise; maxPrecision bounds it.
+const (
+	defaultPrecision = 2
+	maxPrecision     = 9
+)
+
+// clampPrecision limits a --precision value to 0–maxPrecision decimals.
+func clampPrecision(p int) int {
+	return max(0, min(p, maxPrecision))
+}
+
+// Supported --rounding modes.
+const (
+	roundHalfEven = "half-even"
+	roundHalfUp   = "half-up"
+)
+
+// roundingModes lists the valid --rounding values.
+var roundingModes = []string{roundHalfEven, roundHalfUp}
+
+// roundMetric rounds v to places decimals. Ties are decided on the shortest
+// decimal representation of v rather than its binary value, so 2.675 is a
+// tie even though the nearest float64 lies just below it: half-up rounds it
+// away from zero to 2.68, half-even to the even digit, also 2.68 here.
+func roundMetric(v float64, places int, mode string) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	intPart, frac, _ := strings.Cut(strconv.FormatFloat(math.Abs(v), 'f', -1, 64), ".")
+	if len(frac) <= places {
+		return v
+	}
+	n, err := strconv.ParseUint(intPart+frac[:places], 10, 64)
+	if err != nil {
+		// Too many digits to matter at float64 precision.
+		return v
+	}
+	rest := frac[places:]
+	tie := rest[0] == '5' && strings.TrimRight(rest[1:], "0") == ""
+	switch {
+	case rest[0] > '5', rest[0] == '5' && !tie:
+		n++
+	case tie && (mode == roundHalfUp || n%2 == 1):
+		n++
+	}
+	return math.Copysign(float64(n)/math.Pow10(places), v)
+}
+
+// roundTo rounds v to precision decimal places in rounding, one of the
+// --rounding modes. Every report format rounds through it, so they all
+// agree.
+func roundTo(v float64, precision int, rounding string) float64 {
+	return roundMetric(v, precision, rounding)
+}
+
+// formatFloat renders v with precision decimals, e.g. "1.50", rounding ties
+// as roundTo does.
+func formatFloat(v float64, precision int, rounding string) string {
+	return strconv.FormatFloat(roundTo(v, precision, rounding), 'f', precision, 64)
+}
+
+// formatPercent renders a percentage with precision decimals, e.g. "1.50%".
+func formatPercent(v float64, precision int, rounding string) string {
+	return formatFloat(v, precision, rounding) + "%"
+}
+
+// jsonStats is the JSON form of a process's ProcessStats, with the averages
+// computed and every metric rounded to the report precision.
+type jsonStats struct {
+	State         string  `json:"state"`
+	Transitions   int     `json:"state_transitions"`
+	Count         int     `json:"count"`
+	DistinctPIDs  int     `json:"distinct_pids"`
+	AvgCPU        float64 `json:"avg_cpu"`
+	MinCPU        float64 `json:"min_cpu"`
+	MaxCPU        float64 `json:"max_cpu"`
+	MaxCPUTime    string  `json:"max_cpu_time"`
+	LatestCPU     float64 `json:"latest_cpu"`
+	AvgMemory     float64 `json:"avg_rss_mb"`
+	MinMemory     float64 `json:"min_rss_mb"`
+	MaxMemory     float64 `json:"max_rss_mb"`
+	MaxMemoryTime string  `json:"max_rss_time"`
+	LatestMemory  float64 `json:"latest_rss_mb"`
+	AvgPSS        float64 `json:"avg_pss_mb"`
+	MinPSS        float64 `json:"min_pss_mb"`
+	MaxPSS        float64 `json:"max_pss_mb"`
+	MaxPSSTime    string  `json:"max_pss_time"`
+	LatestPSS     float64 `json:"latest_pss_mb"`
+	AvgVSZ        float64 `json:"avg_vsz_mb"`
+	MinVSZ        float64 `json:"min_vsz_mb"`
+	MaxVSZ        float64 `json:"max_vsz_mb"`
+	MaxVSZTime    string  `json:"max_vsz_time"`
+	LatestVSZ     float64 `json:"latest_vsz_mb"`
+	AvgThreads    float64 `json:"avg_threads,omitempty"`
+	MinThreads    int     `json:"min_threads,omitempty"`
+	MaxThreads    int     `json:"max_threads,omitempty"`
+	LatestTime    string  `json:"latest_time"`
+	FirstTime     string  `json:"first_time"`
+	WindowSec     float64 `json:"window_sec"`
+}
+
+func newJSONStats(stat parse.ProcessStats, precision int, rounding string) jsonStats {
+	n := float64(stat.Count)
+	round := func(v float64) float64 { return roundTo(v, precision, rounding) }
+	js := jsonStats{
+		State:         stat.State,
+		Transitions:   stat.Transitions,
+		Count:         stat.Count,
+		DistinctPIDs:  stat.DistinctPIDs,
+		AvgCPU:        round(stat.TotalCPU / n),
+		MinCPU:        round(stat.MinCPU),
+		MaxCPU:        round(stat.MaxCPU),
+		MaxCPUTime:    stat.MaxCPUTime,
+		LatestCPU:     round(stat.LatestCPU),
+		AvgMemory:     round(stat.TotalMemory / n),
+		MinMemory:     round(stat.MinMemory),
+		MaxMemory:     round(stat.MaxMemory),
+		MaxMemoryTime: stat.MaxMemoryTime,
+		LatestMemory:  round(stat.LatestMemory),
+		AvgPSS:        round(stat.TotalPSS / n),
+		MinPSS:        round(stat.MinPSS),
+		MaxPSS:        round(stat.MaxPSS),
+		MaxPSSTime:    stat.MaxPSSTime,
+		LatestPSS:     round(stat.LatestPSS),
+		AvgVSZ:        round(stat.TotalVSZ / n),
+		MinVSZ:        round(stat.MinVSZ),
+		MaxVSZ:        round(stat.MaxVSZ),
+		MaxVSZTime:    stat.MaxVSZTime,
+		LatestVSZ:     round(stat.LatestVSZ),
+		LatestTime:    stat.LatestTime.Format("2006-01-02 15:04:05"),
+		FirstTime:     stat.FirstTime.Format("2006-01-02 15:04:05"),
+		WindowSec:     round(stat.LatestTime.Sub(stat.FirstTime).Seconds()),
+	}
+	if stat.ThreadSamples > 0 {
+		js.AvgThreads = round(float64(stat.TotalThreads) / float64(stat.ThreadSamples))
+		js.MinThreads = stat.MinThreads
+		js.MaxThreads = stat.MaxThreads
+	}
+	return js
+}
+
+// printStatsJSON writes stats as a JSON object keyed by process name.
+// encoding/json sorts map keys, so the output is stable between runs.
+func printStatsJSON(w io.Writer, stats map[string]parse.ProcessStats, precision int, rounding string) error {
+	out := make(map[string]jsonStats, len(stats))
+	for name, stat := range stats {
+		out[name] = newJSONStats(stat, precision, rounding)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// csvHeader is the header row written by printStatsCSV.
+var csvHeader = []string{
+	"name", "state", "count",
+	"avg_cpu", "min_cpu", "max_cpu", "latest_cpu",
+	"avg_rss_mb", "min_rss_mb", "max_rss_mb", "latest_rss_mb",
+	"avg_pss_mb", "min_pss_mb", "max_pss_mb", "latest_pss_mb",
+}
+
+// printStatsCSV writes stats as CSV: a header row followed by one row per
+// process, ordered by name. The columns are, in order: name, state, count,
+// then avg, min, max and latest of CPU (%), RSS (MB) and PSS (MB). Values
+// are rounded to precision decimals.
+func printStatsCSV(w io.Writer, stats map[string]parse.ProcessStats, precision int, rounding string) error {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	num := func(v float64) string { return formatFloat(v, precision, rounding) }
+	cw := csv.NewWriter(w)
+	_ = cw.Write(csvHeader)
+	for _, name := range names {
+		stat := stats[name]
+		n := float64(stat.Count)
+		_ = cw.Write([]string{
+			name, stat.State, strconv.Itoa(stat.Count),
+			num(stat.TotalCPU / n), num(stat.MinCPU), num(stat.MaxCPU), num(stat.LatestCPU),
+			num(stat.TotalMemory / n), num(stat.MinMemory), num(stat.MaxMemory), num(stat.LatestMemory),
+			num(stat.TotalPSS / n), num(stat.MinPSS), num(stat.MaxPSS), num(stat.LatestPSS),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// printStatsMarkdown writes stats as a GitHub-flavored Markdown table, one
+// row per process ordered by name. Pipes in process names are escaped so
+// they do not split the row.
+func printStatsMarkdown(w io.Writer, stats map[string]parse.ProcessStats, precision int, rounding string) error {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintln(bw, "| Process | State | Avg CPU (%) | Max CPU (%) | Avg RSS (MB) | Max RSS (MB) | Avg PSS (MB) | Max PSS (MB) |")
+	_, _ = fmt.Fprintln(bw, "|---|---|--:|--:|--:|--:|--:|--:|")
+	for _, name := range names {
+		stat := stats[name]
+		n := float64(stat.Count)
+		num := func(v float64) string { return formatFloat(v, precision, rounding) }
+		_, _ = fmt.Fprintf(bw, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			markdownEscape(name), markdownEscape(stat.State),
+			num(stat.TotalCPU/n), num(stat.MaxCPU), num(stat.TotalMemory/n), num(stat.MaxMemory), num(stat.TotalPSS/n), num(stat.MaxPSS))
+	}
+	return bw.Flush()
+}
+
+// printStatsCompact writes one aligned line per process in sort order with
+// its latest state, CPU, RSS and PSS and its sample count.
+func printStatsCompact(out io.Writer, stats map[string]parse.ProcessStats, sortBy string, precision int, rounding string) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tSTATE\tCPU%\tRSS_MB\tPSS_MB\tCOUNT")
+	for _, name := range sortedNames(stats, sortBy) {
+		stat := stats[name]
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", name, stat.State,
+			formatFloat(stat.LatestCPU, precision, rounding), formatFloat(stat.LatestMemory, precision, rounding),
+			formatFloat(stat.LatestPSS, precision, rounding), stat.Count)
+	}
+	return w.Flush()
+}
+
+// markdownEscape escapes the characters that would break a table cell.
+var markdownEscape = strings.NewReplacer(`|`, `\|`, "\n", " ").Replace
+
+// Supported --unit values.
+const (
+	unitMB   = "mb"
+	unitGB   = "gb"
+	unitAuto = "auto"
+)
+
+// memUnits lists the valid --unit values.
+var memUnits = []string{unitMB, unitGB, unitAuto}
+
+// formatMem formats a memory value stored in MB in the given unit. unitAuto
+// picks MB, GB or TB by magnitude.
+func formatMem(valMB float64, unit string, precision int, rounding string) string {
+	switch {
+	case unit == unitGB:
+		return formatFloat(valMB/1024, precision, rounding) + " GB"
+	case unit == unitAuto && math.Abs(valMB) >= 1024*1024:
+		return formatFloat(valMB/(1024*1024), precision, rounding) + " TB"
+	case unit == unitAuto && math.Abs(valMB) >= 1024:
+		return formatFloat(valMB/1024, precision, rounding) + " GB"
+	}
+	return formatFloat(valMB, precision, rounding) + " MB"
+}
+
+// memFormatter returns formatMem bound to unit and precision.
+func memFormatter(unit string, precision int, rounding string) func(float64) string {
+	return func(valMB float64) string { return formatMem(valMB, unit, precision, rounding) }
+}
+
+// unitLabel is the unit suffix for report labels, e.g. " (MB)". With
+// unitAuto every value carries its own unit, so the label has none.
+func unitLabel(unit string) string {
+	switch unit {
+	case unitGB:
+		return " (GB)"
+	case unitAuto:
+		return ""
+	}
+	return " (MB)"
+}
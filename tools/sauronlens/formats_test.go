@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestPrintStatsJSONGolden(t *testing.T) {
+	stats := aggregate(t, testOptions(),
+		logLine(1, "foo", "Running", 10.5, 50, "2025-02-21T12:41:52Z"),
+		logLine(1, "foo", "Sleeping (interruptible)", 30, 0.45, "2025-02-21T12:42:52Z"),
+	)
+	var buf bytes.Buffer
+	if err := printStatsJSON(&buf, stats, 2, roundHalfEven); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{
+  "foo": {
+    "state": "Sleeping (interruptible)",
+    "state_transitions": 1,
+    "count": 2,
+    "distinct_pids": 1,
+    "avg_cpu": 25.22,
+    "min_cpu": 0.45,
+    "max_cpu": 50,
+    "max_cpu_time": "2025-02-21 12:41:52",
+    "latest_cpu": 0.45,
+    "avg_rss_mb": 20.25,
+    "min_rss_mb": 10.5,
+    "max_rss_mb": 30,
+    "max_rss_time": "2025-02-21 12:42:52",
+    "latest_rss_mb": 30,
+    "avg_pss_mb": 5,
+    "min_pss_mb": 5,
+    "max_pss_mb": 5,
+    "max_pss_time": "2025-02-21 12:41:52",
+    "latest_pss_mb": 5,
+    "avg_vsz_mb": 20,
+    "min_vsz_mb": 20,
+    "max_vsz_mb": 20,
+    "max_vsz_time": "2025-02-21 12:41:52",
+    "latest_vsz_mb": 20,
+    "avg_threads": 2,
+    "min_threads": 2,
+    "max_threads": 2,
+    "latest_time": "2025-02-21 12:42:52",
+    "first_time": "2025-02-21 12:41:52",
+    "window_sec": 60
+  }
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("printStatsJSON output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintStatsCSVRoundTrip(t *testing.T) {
+	stats := aggregate(t, testOptions(),
+		logLine(1, "foo, bar", "Running", 10, 2, "2025-02-21T12:41:52Z"),
+		logLine(1, "foo, bar", "Running", 20, 4, "2025-02-21T12:42:52Z"),
+		logLine(2, "baz", "Sleeping (interruptible)", 5.25, 0.5, "2025-02-21T12:41:52Z"),
+	)
+	var buf bytes.Buffer
+	if err := printStatsCSV(&buf, stats, 2, roundHalfEven); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV back: %v", err)
+	}
+	want := [][]string{
+		csvHeader,
+		{"baz", "Sleeping (interruptible)", "1", "0.50", "0.50", "0.50", "0.50", "5.25", "5.25", "5.25", "5.25", "5.00", "5.00", "5.00", "5.00"},
+		{"foo, bar", "Running", "2", "3.00", "2.00", "4.00", "4.00", "15.00", "10.00", "20.00", "20.00", "5.00", "5.00", "5.00", "5.00"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV records:\n%q\nwant:\n%q", records, want)
+	}
+}
+
+func TestPrintStatsMarkdown(t *testing.T) {
+	stats := map[string]parse.ProcessStats{
+		"a|b": {State: "Running", Count: 2, TotalCPU: 3, MaxCPU: 2.5, TotalMemory: 30, MaxMemory: 20, TotalPSS: 8, MaxPSS: 4.125},
+	}
+	var buf bytes.Buffer
+	if err := printStatsMarkdown(&buf, stats, 2, roundHalfEven); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"| Process | State | Avg CPU (%) | Max CPU (%) | Avg RSS (MB) | Max RSS (MB) | Avg PSS (MB) | Max PSS (MB) |",
+		"|---|---|--:|--:|--:|--:|--:|--:|",
+		`| a\|b | Running | 1.50 | 2.50 | 15.00 | 20.00 | 4.00 | 4.12 |`,
+		"",
+	}
+	if got := strings.Split(buf.String(), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Markdown lines:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestFormatMem(t *testing.T) {
+	tests := []struct {
+		mb   float64
+		unit string
+		want string
+	}{
+		{512, unitMB, "512.00 MB"},
+		{4096, unitMB, "4096.00 MB"},
+		{512, unitGB, "0.50 GB"},
+		{4096, unitGB, "4.00 GB"},
+		{512, unitAuto, "512.00 MB"},
+		{4096, unitAuto, "4.00 GB"},
+		{2 * 1024 * 1024, unitAuto, "2.00 TB"},
+	}
+	for _, tt := range tests {
+		if got := formatMem(tt.mb, tt.unit, 2, roundHalfEven); got != tt.want {
+			t.Errorf("formatMem(%v, %s) = %q, want %q", tt.mb, tt.unit, got, tt.want)
+		}
+	}
+}
+
+// decimals returns the number of digits after the decimal point in s, which
+// holds a single number possibly followed by a unit.
+func decimals(s string) int {
+	_, frac, ok := strings.Cut(strings.Fields(s)[0], ".")
+	if !ok {
+		return 0
+	}
+	return len(strings.TrimRight(frac, "%"))
+}
+
+func TestPrecision(t *testing.T) {
+	stats := aggregate(t, testOptions(),
+		logLine(1, "foo", "Running", 10.98765432, 3.14159265, "2025-02-21T12:00:00Z"),
+		logLine(2, "bar", "Running", 1.5, 1.5, "2025-02-21T12:00:00Z"), // trailing zeros
+	)
+	for _, precision := range []int{0, 2, 4} {
+		opts := testOptions()
+		opts.precision = precision
+		text := textReport(t, stats, opts)
+		if got := reportValue(text, "Avg CPU Usage:"); decimals(got) != precision {
+			t.Errorf("precision %d: text Avg CPU Usage %q", precision, got)
+		}
+		if got := reportValue(text, "Avg RSS (MB):"); decimals(got) != precision {
+			t.Errorf("precision %d: text Avg RSS %q", precision, got)
+		}
+
+		var csvOut bytes.Buffer
+		if err := printStatsCSV(&csvOut, stats, precision, roundHalfEven); err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(&csvOut).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records[1:] {
+			for i, got := range record[3:] {
+				if decimals(got) != precision {
+					t.Errorf("precision %d: CSV %s %s %q", precision, record[0], csvHeader[i+3], got)
+				}
+			}
+		}
+
+		var jsonOut bytes.Buffer
+		if err := printStatsJSON(&jsonOut, stats, precision, roundHalfEven); err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[string]map[string]any
+		dec := json.NewDecoder(&jsonOut)
+		dec.UseNumber()
+		if err := dec.Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		if got := decoded["foo"]["avg_cpu"].(json.Number).String(); decimals(got) != precision {
+			t.Errorf("precision %d: JSON avg_cpu %s", precision, got)
+		}
+	}
+	if got := clampPrecision(-3); got != 0 {
+		t.Errorf("clampPrecision(-3) = %d", got)
+	}
+	if got := clampPrecision(99); got != maxPrecision {
+		t.Errorf("clampPrecision(99) = %d", got)
+	}
+}
+
+func TestPrintStatsCompact(t *testing.T) {
+	stats := map[string]parse.ProcessStats{
+		"httpd":  {State: "Running", Count: 12, LatestCPU: 1.5, LatestMemory: 35.95, LatestPSS: 20},
+		"mdnsd":  {State: "Sleeping (interruptible)", Count: 3, LatestCPU: 0, LatestMemory: 4.54, LatestPSS: 2.25},
+		"syslog": {State: "Zombie", Count: 1},
+	}
+	var buf bytes.Buffer
+	if err := printStatsCompact(&buf, stats, sortName, 1, roundHalfEven); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"NAME    STATE                     CPU%  RSS_MB  PSS_MB  COUNT",
+		"httpd   Running                   1.5   36.0    20.0    12",
+		"mdnsd   Sleeping (interruptible)  0.0   4.5     2.2     3",
+		"syslog  Zombie                    0.0   0.0     0.0     1",
+		"",
+	}
+	if got := strings.Split(buf.String(), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("compact lines:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestRoundMetric(t *testing.T) {
+	tests := []struct {
+		v      float64
+		places int
+		even   float64
+		up     float64
+	}{
+		{2.125, 2, 2.12, 2.13},
+		{2.675, 2, 2.68, 2.68}, // a tie in decimal, just below it in binary
+		{-2.125, 2, -2.12, -2.13},
+		{0.5, 0, 0, 1},
+		{1.5, 0, 2, 2},
+		{2.1251, 2, 2.13, 2.13},
+		{2.124, 2, 2.12, 2.12},
+		{2.1, 2, 2.1, 2.1},
+	}
+	for _, tt := range tests {
+		if got := roundMetric(tt.v, tt.places, roundHalfEven); got != tt.even {
+			t.Errorf("roundMetric(%v, %d, half-even) = %v, want %v", tt.v, tt.places, got, tt.even)
+		}
+		if got := roundMetric(tt.v, tt.places, roundHalfUp); got != tt.up {
+			t.Errorf("roundMetric(%v, %d, half-up) = %v, want %v", tt.v, tt.places, got, tt.up)
+		}
+	}
+}
+
+func TestRoundingOption(t *testing.T) {
+	stats := aggregate(t, testOptions(), logLine(1, "foo", "Running", 2.125, 2.125, "2025-02-21T12:00:00Z"))
+	for mode, want := range map[string]string{roundHalfEven: "2.12", roundHalfUp: "2.13"} {
+		opts := testOptions()
+		opts.rounding = mode
+		if got := reportValue(textReport(t, stats, opts), "Avg CPU Usage:"); got != want+"%" {
+			t.Errorf("%s: Avg CPU Usage %q, want %s%%", mode, got, want)
+		}
+		var buf bytes.Buffer
+		if err := printStatsCSV(&buf, stats, opts.precision, opts.rounding); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), ","+want+",") {
+			t.Errorf("%s: CSV %q, want %s", mode, buf.String(), want)
+		}
+	}
+}
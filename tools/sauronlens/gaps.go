@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// gap is a stretch between two consecutive samples that is wider than the
+// expected sampling interval, i.e. a period the collector did not observe.
+type gap struct {
+	Start    time.Time
+	Duration time.Duration
+}
+
+// medianInterval returns the median spacing of samples, which must be sorted
+// by time. It is zero for fewer than two samples.
+func medianInterval(samples []parse.Sample) time.Duration {
+	if len(samples) < 2 {
+		return 0
+	}
+	intervals := make([]time.Duration, 0, len(samples)-1)
+	for i := 1; i < len(samples); i++ {
+		intervals = append(intervals, samples[i].Time.Sub(samples[i-1].Time))
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	mid := len(intervals) / 2
+	if len(intervals)%2 == 0 {
+		return (intervals[mid-1] + intervals[mid]) / 2
+	}
+	return intervals[mid]
+}
+
+// findGaps returns the gaps in samples wider than maxGap or, when maxGap is
+// zero, wider than factor times the median sampling interval. Samples are
+// sorted first, so the input order does not matter.
+func findGaps(samples []parse.Sample, factor float64, maxGap time.Duration) []gap {
+	sorted := sortedByTime(samples)
+	threshold := gapThreshold(sorted, factor, maxGap)
+	if threshold <= 0 {
+		return nil
+	}
+	var gaps []gap
+	for i := 1; i < len(sorted); i++ {
+		if d := sorted[i].Time.Sub(sorted[i-1].Time); d > threshold {
+			gaps = append(gaps, gap{Start: sorted[i-1].Time, Duration: d})
+		}
+	}
+	return gaps
+}
+
+// gapThreshold returns the spacing above which two consecutive samples,
+// which must be sorted by time, count as a gap: maxGap or, when that is
+// zero, factor times the median sampling interval.
+func gapThreshold(sorted []parse.Sample, factor float64, maxGap time.Duration) time.Duration {
+	if maxGap > 0 {
+		return maxGap
+	}
+	return time.Duration(factor * float64(medianInterval(sorted)))
+}
+
+// formatGaps summarizes gaps as a count and total duration.
+func formatGaps(gaps []gap) string {
+	if len(gaps) == 0 {
+		return "none"
+	}
+	var total time.Duration
+	for _, g := range gaps {
+		total += g.Duration
+	}
+	return fmt.Sprintf("%d totalling %s", len(gaps), total.Round(time.Second))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestFindGaps(t *testing.T) {
+	start := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	var samples []parse.Sample
+	at := start
+	for i := 0; i < 20; i++ {
+		if i == 10 {
+			at = at.Add(10 * time.Minute)
+		} else if i > 0 {
+			at = at.Add(10 * time.Second)
+		}
+		samples = append(samples, parse.Sample{Time: at})
+	}
+	gapStart := samples[9].Time
+	rand.New(rand.NewPCG(1, 2)).Shuffle(len(samples), func(i, j int) { samples[i], samples[j] = samples[j], samples[i] })
+
+	gaps := findGaps(samples, 3, 0)
+	if len(gaps) != 1 || gaps[0].Duration != 10*time.Minute || !gaps[0].Start.Equal(gapStart) {
+		t.Fatalf("findGaps = %+v, want one 10m gap starting at %v", gaps, gapStart)
+	}
+	if got := formatGaps(gaps); got != "1 totalling 10m0s" {
+		t.Errorf("formatGaps = %q", got)
+	}
+	if gaps := findGaps(samples, 3, 15*time.Minute); len(gaps) != 0 {
+		t.Errorf("--max-gap 15m: found %+v, want none", gaps)
+	}
+	if gaps := findGaps(samples[:1], 3, 0); len(gaps) != 0 {
+		t.Errorf("single sample: found %+v, want none", gaps)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// groupSuffix matches the instance suffix of names like worker-3 or
+// worker_12, which groupName strips.
+var groupSuffix = regexp.MustCompile(`[-_.]?\d+$`)
+
+// minGroupSize is the fewest members a group needs before its median is a
+// meaningful baseline for outlier detection.
+const minGroupSize = 3
+
+// groupName returns the group a process belongs to: its name without a
+// trailing instance number.
+func groupName(name string) string {
+	if g := groupSuffix.ReplaceAllString(name, ""); g != "" {
+		return g
+	}
+	return name
+}
+
+// outlier is a group member whose latest RSS is far above its group median.
+type outlier struct {
+	Name      string
+	Group     string
+	RSS       float64
+	MedianRSS float64
+}
+
+// findOutliers groups processes by groupName and returns the members whose
+// latest RSS exceeds factor times their group's median, ordered by name.
+// Groups smaller than minGroupSize are skipped.
+func findOutliers(stats map[string]parse.ProcessStats, factor float64) []outlier {
+	groups := make(map[string][]string)
+	for name := range stats {
+		g := groupName(name)
+		groups[g] = append(groups[g], name)
+	}
+
+	var outliers []outlier
+	for g, members := range groups {
+		if len(members) < minGroupSize {
+			continue
+		}
+		rss := make([]float64, len(members))
+		for i, name := range members {
+			rss[i] = stats[name].LatestMemory
+		}
+		median := percentile(rss, 50)
+		for _, name := range members {
+			if v := stats[name].LatestMemory; v > factor*median {
+				outliers = append(outliers, outlier{Name: name, Group: g, RSS: v, MedianRSS: median})
+			}
+		}
+	}
+	sort.Slice(outliers, func(i, j int) bool { return outliers[i].Name < outliers[j].Name })
+	return outliers
+}
+
+// printOutliers writes the outliers found in stats, if any.
+func printOutliers(w io.Writer, stats map[string]parse.ProcessStats, factor float64) {
+	outliers := findOutliers(stats, factor)
+	if len(outliers) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, "Outliers:")
+	for _, o := range outliers {
+		// Any RSS is an outlier against a zero median, but not by a ratio.
+		ratio := "n/a"
+		if o.MedianRSS > 0 {
+			ratio = fmt.Sprintf("%.1fx", o.RSS/o.MedianRSS)
+		}
+		_, _ = fmt.Fprintf(w, "  %s (group %s): %.2f MB RSS, %s group median %.2f MB\n",
+			o.Name, o.Group, o.RSS, ratio, o.MedianRSS)
+	}
+}
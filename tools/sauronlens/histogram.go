@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// Supported --histogram metrics.
+var histogramMetrics = map[string]func(parse.Sample) float64{
+	"cpu": sampleCPU,
+	"rss": sampleRSS,
+	"pss": samplePSS,
+}
+
+// histogramBarWidth is the length of the bar of the fullest bucket.
+const histogramBarWidth = 30
+
+// maxHistogramBuckets caps the buckets of one histogram, so a --bucket-width
+// far below the spread of the values fails instead of exhausting memory.
+const maxHistogramBuckets = 10000
+
+// bucket counts the values in [Lo, Hi).
+type bucket struct {
+	Lo, Hi float64
+	Count  int
+}
+
+// histogram sorts values into buckets of bucketWidth aligned to multiples of
+// it, from the bucket holding the smallest value to the one holding the
+// largest. Empty buckets in between are kept so the shape of the
+// distribution is visible. It fails when that takes more than
+// maxHistogramBuckets buckets.
+func histogram(values []float64, bucketWidth float64) ([]bucket, error) {
+	if len(values) == 0 || bucketWidth <= 0 {
+		return nil, nil
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	first := math.Floor(lo / bucketWidth)
+	// Counted in floating point first, as the int conversion could overflow.
+	n := math.Floor(hi/bucketWidth) - first + 1
+	if !(n <= maxHistogramBuckets) {
+		return nil, fmt.Errorf("--bucket-width %g needs more than %d buckets for values from %g to %g", bucketWidth, maxHistogramBuckets, lo, hi)
+	}
+	buckets := make([]bucket, int(n))
+	for i := range buckets {
+		buckets[i].Lo = (first + float64(i)) * bucketWidth
+		buckets[i].Hi = buckets[i].Lo + bucketWidth
+	}
+	for _, v := range values {
+		buckets[int(math.Floor(v/bucketWidth)-first)].Count++
+	}
+	return buckets, nil
+}
+
+// printHistogram writes buckets as indented rows of a tabwriter, each with its
+// range, a bar scaled to the fullest bucket and its count.
+func printHistogram(w io.Writer, buckets []bucket, format func(float64) string) {
+	most := 0
+	for _, b := range buckets {
+		most = max(most, b.Count)
+	}
+	for _, b := range buckets {
+		bar := strings.Repeat("█", b.Count*histogramBarWidth/most)
+		_, _ = fmt.Fprintf(w, "    %s–%s\t%-*s %d\n", format(b.Lo), format(b.Hi), histogramBarWidth, bar, b.Count)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	values := []float64{12, 14.5, 19.99, 20, 41, 49.9, 11}
+	want := []bucket{
+		{Lo: 10, Hi: 20, Count: 4},
+		{Lo: 20, Hi: 30, Count: 1},
+		{Lo: 30, Hi: 40, Count: 0},
+		{Lo: 40, Hi: 50, Count: 2},
+	}
+	got, err := histogram(values, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("histogram =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got, err := histogram([]float64{7, 7}, 5); err != nil || len(got) != 1 || got[0] != (bucket{Lo: 5, Hi: 10, Count: 2}) {
+		t.Errorf("single bucket = %+v, %v", got, err)
+	}
+	if _, err := histogram([]float64{0, 1e6}, 1e-3); err == nil {
+		t.Errorf("a bucket width needing %g buckets was accepted", 1e9)
+	}
+}
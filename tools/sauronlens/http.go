@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// liveStats is a stats map shared between the goroutine aggregating into it
+// and the HTTP handlers reading it.
+type liveStats struct {
+	mu    sync.Mutex
+	stats map[string]parse.ProcessStats
+}
+
+func newLiveStats() *liveStats {
+	return &liveStats{stats: make(map[string]parse.ProcessStats)}
+}
+
+// update runs fn with the stats map locked for writing.
+func (ls *liveStats) update(fn func(map[string]parse.ProcessStats) error) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return fn(ls.stats)
+}
+
+// snapshot returns a finalized copy of the current stats. The maps and
+// slices inside each ProcessStats are copied too: Update keeps writing to
+// them once the lock is released, while finalizeStats and the report
+// writers read them.
+func (ls *liveStats) snapshot() map[string]parse.ProcessStats {
+	ls.mu.Lock()
+	stats := make(map[string]parse.ProcessStats, len(ls.stats))
+	for key, stat := range ls.stats {
+		stat.Names = maps.Clone(stat.Names)
+		stat.PIDs = maps.Clone(stat.PIDs)
+		stat.Samples = slices.Clone(stat.Samples)
+		stat.Reservoir = slices.Clone(stat.Reservoir)
+		stats[key] = stat
+	}
+	ls.mu.Unlock()
+	finalizeStats(stats)
+	return stats
+}
+
+// statsHandler serves the current stats as JSON at /stats and a liveness
+// check at /healthz.
+func statsHandler(live *liveStats, precision int, rounding string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := printStatsJSON(w, live.snapshot(), precision, rounding); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing /stats:", err)
+		}
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// startHTTP starts serving live on addr in the background.
+func startHTTP(addr string, live *liveStats, precision int, rounding string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: statsHandler(live, precision, rounding), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(os.Stderr, "Error serving HTTP:", err)
+		}
+	}()
+	return srv, nil
+}
+
+// stopHTTP shuts srv down, giving in-flight requests a few seconds to
+// finish.
+func stopHTTP(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+}
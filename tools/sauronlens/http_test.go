@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestStatsHandler(t *testing.T) {
+	live := newLiveStats()
+	srv := httptest.NewServer(statsHandler(live, 2, roundHalfEven))
+	defer srv.Close()
+
+	getStats := func() map[string]jsonStats {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/stats")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() //nolint:errcheck
+		if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "application/json" {
+			t.Fatalf("GET /stats: %s, Content-Type %q", resp.Status, ct)
+		}
+		var stats map[string]jsonStats
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatalf("decoding /stats: %v", err)
+		}
+		return stats
+	}
+
+	if stats := getStats(); len(stats) != 0 {
+		t.Errorf("empty live stats served %v", stats)
+	}
+	for i, line := range []string{
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		logLine(1, "foo", "Running", 20, 3, "2025-02-21T12:01:00Z"),
+	} {
+		err := live.update(func(stats map[string]parse.ProcessStats) error {
+			return aggregateLine(stats, line, i+1, testOptions())
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	foo, ok := getStats()["foo"]
+	if !ok || foo.Count != 2 || foo.MaxMemory != 20 || foo.AvgCPU != 2 {
+		t.Errorf("/stats foo = %+v", foo)
+	}
+
+	resp, err := http.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "ok\n" {
+		t.Errorf("GET /healthz: %s %q", resp.Status, body)
+	}
+}
+
+// TestStatsHandlerWhileFollowing serves /stats while lines are aggregated
+// the way follow does, renaming a tracked PID so its Names map keeps
+// changing. Run with -race.
+func TestStatsHandlerWhileFollowing(t *testing.T) {
+	live := newLiveStats()
+	srv := httptest.NewServer(statsHandler(live, 2, roundHalfEven))
+	defer srv.Close()
+
+	opts := testOptions()
+	opts.trackByPID = true
+	opts.retainSamples = true
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		start := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				done <- nil
+				return
+			default:
+			}
+			line := logLine(1, fmt.Sprintf("worker-%d", i%7), "Running", float64(i), 1, start.Add(time.Duration(i)*time.Second).Format(time.RFC3339))
+			err := live.update(func(stats map[string]parse.ProcessStats) error {
+				if i%5000 == 0 {
+					// Keep the retained samples quick to copy.
+					clear(stats)
+				}
+				return aggregateLine(stats, line, i+1, opts)
+			})
+			if err != nil {
+				done <- err
+				return
+			}
+			runtime.Gosched() // let the handlers take the lock
+		}
+	}()
+
+	for range 50 {
+		resp, err := http.Get(srv.URL + "/stats")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
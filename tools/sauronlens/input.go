@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// Supported --input-format values.
+const (
+	inputLines  = "lines"  // newline-terminated log lines
+	inputFramed = "framed" // 4-byte big-endian length followed by one log line
+)
+
+// maxFrameSize bounds the length prefix of a framed record so corrupt input
+// cannot trigger a huge allocation.
+const maxFrameSize = 16 << 20
+
+// lineScanner yields log lines one at a time; *bufio.Scanner satisfies it.
+type lineScanner interface {
+	Scan() bool
+	Text() string
+	Err() error
+}
+
+// newLineScanner returns a scanner for r in the given input format. Lines
+// longer than maxLine bytes are cut short rather than ending the scan; see
+// boundedScanner.
+func newLineScanner(r io.Reader, format string, maxLine int) lineScanner {
+	if format == inputFramed {
+		return &frameScanner{r: bufio.NewReader(r)}
+	}
+	return &boundedScanner{r: bufio.NewReader(r), max: maxLine}
+}
+
+// boundedScanner reads newline-terminated lines like a bufio.Scanner, whose
+// scan stops for good at the first line beyond its buffer. Here a line longer
+// than max bytes is instead returned cut to max+1 bytes, with the rest of it
+// discarded, so memory stays bounded and parseLine can reject just that line.
+type boundedScanner struct {
+	r    *bufio.Reader
+	max  int
+	line string
+	err  error
+}
+
+func (bs *boundedScanner) Scan() bool {
+	if bs.err != nil {
+		return false
+	}
+	var line []byte
+	for {
+		chunk, isPrefix, err := bs.r.ReadLine()
+		if err != nil {
+			if err != io.EOF {
+				bs.err = err
+			}
+			return false
+		}
+		line = appendBounded(line, chunk, bs.max)
+		if !isPrefix {
+			break
+		}
+	}
+	bs.line = string(line)
+	return true
+}
+
+func (bs *boundedScanner) Text() string { return bs.line }
+
+// appendBounded appends chunk, a piece of a line, to line while keeping at
+// most max+1 bytes of it: enough for parseLine to tell the line is too long.
+func appendBounded(line, chunk []byte, max int) []byte {
+	if room := max + 1 - len(line); room > 0 {
+		line = append(line, chunk[:min(len(chunk), room)]...)
+	}
+	return line
+}
+
+func (bs *boundedScanner) Err() error { return bs.err }
+
+// limitScanner stops after left lines, leaving the rest of the input unread.
+type limitScanner struct {
+	lineScanner
+	left int
+}
+
+func (ls *limitScanner) Scan() bool {
+	if ls.left <= 0 {
+		return false
+	}
+	ls.left--
+	return ls.lineScanner.Scan()
+}
+
+// frameScanner reads length-prefixed records, so log lines may safely
+// contain newlines.
+type frameScanner struct {
+	r    io.Reader
+	line string
+	err  error
+}
+
+func (fs *frameScanner) Scan() bool {
+	if fs.err != nil {
+		return false
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(fs.r, header[:]); err != nil {
+		if !errors.Is(err, io.EOF) {
+			fs.err = fmt.Errorf("truncated frame header: %w", err)
+		}
+		return false
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		fs.err = fmt.Errorf("frame of %d bytes exceeds the %d byte limit", size, maxFrameSize)
+		return false
+	}
+
+	buf := make([]byte, size)
+	if n, err := io.ReadFull(fs.r, buf); err != nil {
+		fs.err = fmt.Errorf("truncated frame: got %d of %d bytes", n, size)
+		return false
+	}
+	fs.line = string(buf)
+	return true
+}
+
+func (fs *frameScanner) Text() string { return fs.line }
+
+func (fs *frameScanner) Err() error { return fs.err }
+
+// gzipMagic is the header that starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns a reader that transparently gunzips r when it starts
+// with the gzip magic bytes, detected by content so compressed files and
+// piped gzip data both work. The returned close function releases the gzip
+// reader, if any.
+func decompress(r io.Reader) (io.Reader, func() error, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		// Too short to be gzip, or plain text.
+		return br, func() error { return nil }, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decompressing input: %w", err)
+	}
+	return gzipErrReader{zr}, zr.Close, nil
+}
+
+// gzipErrReader labels decompression errors so they are not mistaken for
+// problems with the log content.
+type gzipErrReader struct {
+	zr *gzip.Reader
+}
+
+func (g gzipErrReader) Read(p []byte) (int, error) {
+	n, err := g.zr.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("decompressing input: %w", err)
+	}
+	return n, err
+}
+
+// processFiles aggregates the log files at paths into one stats map as if
+// they were concatenated, streaming one file at a time. A file that cannot
+// be read is reported on stderr and skipped, unless strict is set, in which
+// case processing stops with an error naming the file.
+func processFiles(paths []string, opts options, strict bool) (map[string]parse.ProcessStats, error) {
+	stats := make(map[string]parse.ProcessStats)
+	for _, path := range paths {
+		if err := aggregateFile(stats, path, opts); err != nil {
+			if strict || errors.Is(err, errTooManyErrors) {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		}
+	}
+	finalizeStats(stats)
+	return stats, nil
+}
+
+// aggregateFile merges the log file at path into stats.
+func aggregateFile(stats map[string]parse.ProcessStats, path string, opts options) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint:errcheck
+	opts.source = path
+	if opts.progress == nil {
+		return aggregateReader(stats, file, opts)
+	}
+	var size int64
+	if fi, err := file.Stat(); err == nil && fi.Mode().IsRegular() {
+		size = fi.Size()
+	}
+	progress := newProgressReader(file, opts.progress, path, size)
+	defer progress.done()
+	return aggregateReader(stats, progress, opts)
+}
+
+// aggregateReader merges the log data of r into stats, decompressing it
+// first if it is gzipped.
+func aggregateReader(stats map[string]parse.ProcessStats, r io.Reader, opts options) error {
+	r, closeReader, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	defer closeReader() //nolint:errcheck
+	return aggregateLogs(stats, r, opts)
+}
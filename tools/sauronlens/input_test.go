@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// gzipped returns data compressed with gzip.
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAggregateReaderGzip(t *testing.T) {
+	log := logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:41:52Z") + "\n" +
+		logLine(1, "foo", "Running", 20, 1, "2025-02-21T12:42:52Z") + "\n"
+	for _, tt := range []struct {
+		name  string
+		input []byte
+	}{
+		{"plain", []byte(log)},
+		{"gzip", gzipped(t, log)},
+	} {
+		stats := map[string]parse.ProcessStats{}
+		if err := aggregateReader(stats, bytes.NewReader(tt.input), testOptions()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := stats["foo"]; got.Count != 2 || got.MaxMemory != 20 {
+			t.Errorf("%s: foo count %d, max RSS %v; want 2 and 20", tt.name, got.Count, got.MaxMemory)
+		}
+	}
+}
+
+func TestAggregateReaderCorruptGzip(t *testing.T) {
+	data := gzipped(t, strings.Repeat(logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:41:52Z")+"\n", 100))
+	data = data[:len(data)/2]
+	err := aggregateReader(map[string]parse.ProcessStats{}, bytes.NewReader(data), testOptions())
+	if err == nil || !strings.Contains(err.Error(), "decompressing input") {
+		t.Errorf("truncated gzip: err = %v, want a decompression error", err)
+	}
+}
+
+// writeLog writes lines as a log file named name in dir and returns its path.
+func writeLog(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProcessFilesMergesOverlappingNames(t *testing.T) {
+	dir := t.TempDir()
+	a := writeLog(t, dir, "a.log",
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		logLine(2, "bar", "Running", 5, 1, "2025-02-21T12:00:00Z"),
+	)
+	b := writeLog(t, dir, "b.log",
+		logLine(1, "foo", "Running", 30, 1, "2025-02-21T13:00:00Z"),
+	)
+	missing := filepath.Join(dir, "missing.log")
+
+	stats, err := processFiles([]string{a, missing, b}, testOptions(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if foo := stats["foo"]; foo.Count != 2 || foo.MinMemory != 10 || foo.MaxMemory != 30 || foo.LatestMemory != 30 {
+		t.Errorf("foo = count %d, RSS %v–%v latest %v; want 2 samples spanning both files", foo.Count, foo.MinMemory, foo.MaxMemory, foo.LatestMemory)
+	}
+	if bar := stats["bar"]; bar.Count != 1 {
+		t.Errorf("bar count = %d, want 1", bar.Count)
+	}
+
+	if _, err := processFiles([]string{a, missing, b}, testOptions(), true); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("strict: err = %v, want one naming %s", err, missing)
+	}
+}
+
+func TestLimitLines(t *testing.T) {
+	opts := testOptions()
+	opts.limitLines = 3
+	stats, skips, err := processLogs(strings.NewReader(strings.Join([]string{
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		"garbage",
+		logLine(1, "foo", "Running", 20, 1, "2025-02-21T12:01:00Z"),
+		logLine(1, "foo", "Running", 99, 1, "2025-02-21T12:02:00Z"),
+		logLine(2, "bar", "Running", 5, 1, "2025-02-21T12:02:00Z"),
+	}, "\n")), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The malformed line counts towards the limit.
+	if foo := stats["foo"]; len(stats) != 1 || foo.Count != 2 || foo.MaxMemory != 20 || skips.Skipped != 1 {
+		t.Errorf("--limit-lines 3: %d processes, foo count %d max %v, %d skipped; want 1, 2, 20, 1",
+			len(stats), foo.Count, foo.MaxMemory, skips.Skipped)
+	}
+}
+
+func TestOversizedLineSkipped(t *testing.T) {
+	valid := logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z")
+	log := strings.Join([]string{
+		valid,
+		valid + strings.Repeat("x", 4096),
+		logLine(1, "foo", "Running", 20, 1, "2025-02-21T12:01:00Z"),
+	}, "\n")
+
+	opts := testOptions()
+	opts.maxLineBytes = 1024
+	stats, skips, err := processLogs(strings.NewReader(log), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skips.Skipped != 1 || !strings.Contains(skips.First[0], "line 2: line longer than --max-line-bytes 1024") {
+		t.Errorf("skips = %d %q, want line 2 too long", skips.Skipped, skips.First)
+	}
+	if foo := stats["foo"]; foo.Count != 2 || foo.MaxMemory != 20 {
+		t.Errorf("foo count %d, max RSS %v; want 2 and 20", foo.Count, foo.MaxMemory)
+	}
+}
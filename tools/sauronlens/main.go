@@ -2,262 +2,1640 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"maps"
+	"math"
+	"math/rand/v2"
 	"os"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
+	"text/template"
 	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
 )
 
-type ProcessStats struct {
-	TotalCPU      float64
-	TotalMemory   float64
-	TotalPSS      float64
-	MinMemory     float64
-	MaxMemory     float64
-	MinPSS        float64
-	MaxPSS        float64
-	MinCPU        float64
-	MaxCPU        float64
-	Count         int
-	MaxMemoryTime string
-	MaxPSSTime    string
-	MaxCPUTime    string
-	LatestCPU     float64
-	LatestMemory  float64
-	LatestPSS     float64
-	LatestTime    time.Time
-	State         string
-}
-
-type LogEntry struct {
-	Name      string
-	State     string
-	CPU       float64
-	Memory    float64 // RSS in MB
-	PSS       float64 // PSS in MB
-	Timestamp time.Time
-}
-
-// parseLogEntry parses a single log line into a LogEntry struct.
-func parseLogEntry(line string) (*LogEntry, error) {
-	parts := strings.Split(line, " | ")
-	if len(parts) < 10 {
-		return nil, fmt.Errorf("insufficient log parts: %d", len(parts))
-	}
-
-	// parts indices:
-	// 0: PID: ...
-	// 1: Name: ...
-	// 2: State: ...
-	// 3: Threads: ...
-	// 4: RSS (MB): ...
-	// 5: VSZ (MB): ...
-	// 6: PSS (MB): ...
-	// 7: CPU (%): ...
-	// 8: Uptime (sec): ...
-	// 9: Last Checked: ...
-
-	// Name
-	nameParts := strings.Split(parts[1], ": ")
-	if len(nameParts) != 2 {
-		return nil, fmt.Errorf("invalid process name format")
-	}
-	name := nameParts[1]
-
-	// State
-	stateParts := strings.Split(parts[2], ": ")
-	if len(stateParts) != 2 {
-		return nil, fmt.Errorf("invalid process state format")
-	}
-	state := stateParts[1]
-
-	// Memory (RSS)
-	memParts := strings.Split(parts[4], ": ")
-	if len(memParts) != 2 {
-		return nil, fmt.Errorf("invalid RSS format")
-	}
-	memory, err := strconv.ParseFloat(strings.TrimSpace(memParts[1]), 64)
+// flattenedName is the single stats key used when --flatten pools every
+// sample into one aggregate.
+const flattenedName = "(all processes)"
+
+// options controls how log lines are turned into stats.
+type options struct {
+	// parse overrides parse.ParseLogEntry, e.g. with a --regex parser. It
+	// must be safe for concurrent use by the --workers goroutines.
+	parse func(string) (*parse.LogEntry, error)
+
+	inputFormat  string         // inputLines or inputFramed
+	workers      int            // goroutines parsing lines; 1 parses inline
+	dedup        bool           // drop lines repeating a process's latest timestamp
+	cpuFraction  bool           // CPU field is a 0–1 fraction rather than a percent
+	flatten      bool           // pool every sample under flattenedName
+	process      string         // only aggregate the process with this exact name
+	since        time.Time      // only aggregate samples at or after this; zero is open
+	until        time.Time      // only aggregate samples at or before this; zero is open
+	filter       *regexp.Regexp // only aggregate processes whose name matches
+	include      nameList       // if set, only aggregate processes matching one of these
+	namePattern  *regexp.Regexp // if set, aggregate names under the text of its first group
+	exclude      nameList       // never aggregate processes matching one of these
+	byState      bool           // print process counts and RSS per latest state
+	byPID        bool           // key stats on name#pid, one entry per process instance
+	trackByPID   bool           // key stats on PID, merging a process's renames
+	errLog       io.Writer      // receives every line that fails to parse, if set
+	progress     io.Writer      // receives a progress line while reading inputs, if set
+	sqlite       *sqliteExport  // archives every aggregated entry, if set
+	limitLines   int            // stop each input after this many lines, parsed or not; 0 reads all
+	maxLineBytes int            // lines longer than this are skipped as malformed
+	reorder      *reorderBuffer // sorts entries within a time window before aggregating, if set
+	keys         *keyLimiter    // evicts and reports the least recently updated processes beyond --max-keys, if set
+	ndjson       io.Writer      // receives each aggregated entry as a JSON line instead of reports, if set
+	validate     bool           // reject lines deviating from parse.SchemaFields and fail the run
+	source       string         // name of the input being read, for error reports
+
+	// skips, if set, collects parse failures across every input; reading
+	// fails once more than maxErrors lines were skipped (-1 for no limit).
+	skips     *skipReport
+	maxErrors int
+
+	// coverage, if set, counts valid values per field across all lines.
+	coverage *fieldCoverage
+
+	// retainSamples keeps every sample per process for the metrics that
+	// cannot be computed from running totals, such as percentiles.
+	retainSamples bool
+
+	// percentiles reports p50/p95/p99, computed from every retained sample
+	// or, in percentileApprox mode, from a bounded reservoir.
+	percentiles    bool
+	percentileMode string
+	rng            *rand.Rand // drives reservoir sampling
+
+	out           io.Writer // where reports are written: stdout or --output
+	format        string    // formatText, formatJSON or formatCSV
+	unit          string    // unit memory is printed in: unitMB, unitGB or unitAuto
+	precision     int       // decimals metrics are printed with
+	rounding      string    // how ties round to precision: roundHalfEven or roundHalfUp
+	noSummary     bool      // leave out the totals footer of the text report
+	color         highlighter
+	sortBy        string             // order of processes in the report
+	top           int                // only report the first top processes in sortBy order; 0 for all
+	stateTimeline bool               // print a downsampled per-process state timeline
+	sparkline     bool               // print a downsampled per-process RSS sparkline
+	histogram     string             // print a per-process histogram of this metric; "" disables
+	zscore        float64            // flag latest readings this many standard deviations from the mean; 0 disables
+	cpuSeconds    bool               // print the CPU time consumed over the window, excluding sampling gaps
+	minUptime     time.Duration      // flag processes whose latest uptime is below this; 0 disables
+	host          string             // host of entries without a Host field; "" keeps them ungrouped
+	template      *template.Template // per-process layout of formatTemplate
+	bucketWidth   float64            // width of each --histogram bucket, in MB or percent
+	burstiness    bool               // print the share of samples above average CPU
+	ewmaAlpha     float64            // weight of the newest sample in EWMAs; 0 disables
+
+	resampleStep   time.Duration // grid step for --resample; 0 disables
+	resampleMaxGap time.Duration // widest span --resample interpolates across
+
+	projectTo float64 // RSS ceiling in MB to project growth towards; 0 disables
+
+	// cpuThreshold reports runs of samples with CPU above it lasting at
+	// least cpuDuration; 0 disables.
+	cpuThreshold float64
+	cpuDuration  time.Duration
+
+	// gaps reports sampling gaps wider than maxGap or, when that is zero,
+	// than gapFactor times the median sampling interval.
+	gaps      bool
+	gapFactor float64
+	maxGap    time.Duration
+
+	// detectLeaks flags processes whose RSS trend rises faster than
+	// leakSlope MB/hour with an R² of at least leakR2.
+	detectLeaks bool
+	leakSlope   float64
+	leakR2      float64
+
+	stabilityBand  float64 // RSS band in percent for the stability SLO; 0 disables
+	baselineMetric string  // first, mean or a literal size
+
+	outlierFactor float64 // flag group members above this multiple of the median RSS; 0 disables
+
+	events      []event       // recorded events to correlate with peaks
+	eventWindow time.Duration // how close a peak must be to an event
+
+	alertP95CPU   float64 // alert when p95 CPU exceeds this percent; 0 disables
+	alertGrowth   float64 // alert when RSS grows faster than this many MB/hour; 0 disables
+	failOnZombies int     // alert when at least this many processes are zombies; 0 disables
+	noNegative    bool    // skip lines with a negative CPU, RSS or PSS instead of clamping it to 0
+
+	totalMemory  float64 // host memory in MB for OOM-risk reporting; 0 disables
+	oomThreshold float64 // percent of totalMemory considered OOM risk
+
+	alertsNDJSON string    // file receiving alerts as NDJSON; "-" for stderr
+	exitCodes    exitCodes // per-category exit code overrides
+	exitPriority []string  // alert categories from most to least urgent
+
+	// requests maps process names to the number of requests they served
+	// during the capture, enabling per-request cost estimates.
+	requests requestCounts
+}
+
+// requestCounts is a repeatable name:count flag.
+type requestCounts map[string]float64
+
+func (rc requestCounts) String() string {
+	parts := make([]string, 0, len(rc))
+	for name, n := range rc {
+		parts = append(parts, fmt.Sprintf("%s:%g", name, n))
+	}
+	return strings.Join(parts, ",")
+}
+
+// sizeFlag is a memory size flag such as 512MB or 16GB, stored in MB.
+type sizeFlag float64
+
+func (sf *sizeFlag) String() string { return fmt.Sprintf("%gMB", float64(*sf)) }
+
+func (sf *sizeFlag) Set(value string) error {
+	mb, err := parseSize(value)
 	if err != nil {
-		return nil, fmt.Errorf("invalid RSS value: %v", err)
+		return err
 	}
+	*sf = sizeFlag(mb)
+	return nil
+}
 
-	// PSS
-	pssParts := strings.Split(parts[6], ": ")
-	if len(pssParts) != 2 {
-		return nil, fmt.Errorf("invalid PSS format")
+// parseSize parses a memory size with an optional binary unit suffix (B, KB,
+// MB, GB, TB) into MB. A bare number is taken as MB.
+func parseSize(value string) (float64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multipliers := []struct {
+		suffix string
+		mb     float64
+	}{
+		{"TB", 1024 * 1024}, {"GB", 1024}, {"MB", 1}, {"KB", 1.0 / 1024},
+		{"T", 1024 * 1024}, {"G", 1024}, {"M", 1}, {"K", 1.0 / 1024}, {"B", 1.0 / (1024 * 1024)},
 	}
-	pss, err := strconv.ParseFloat(strings.TrimSpace(pssParts[1]), 64)
-	if err != nil {
-		return nil, fmt.Errorf("invalid PSS value: %v", err)
+	mult := 1.0
+	for _, m := range multipliers {
+		if strings.HasSuffix(s, m.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, m.suffix))
+			mult = m.mb
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
 	}
+	return n * mult, nil
+}
 
-	// CPU
-	cpuParts := strings.Split(parts[7], ": ")
-	if len(cpuParts) != 2 {
-		return nil, fmt.Errorf("invalid CPU usage format")
+func (rc requestCounts) Set(value string) error {
+	name, count, ok := strings.Cut(value, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name:count, got %q", value)
 	}
-	cpu, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(cpuParts[1]), "%"), 64)
-	if err != nil {
-		return nil, fmt.Errorf("invalid CPU value: %v", err)
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid request count %q", count)
 	}
+	rc[name] = n
+	return nil
+}
 
-	// Timestamp
-	tsStr := strings.TrimPrefix(parts[9], "Last Checked: ")
-	timestamp, err := time.Parse(time.RFC3339Nano, tsStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid timestamp: %v", err)
+// pidKeyPrefix marks stats keys that hold a PID until finalizeStats
+// replaces them with the process's name.
+const pidKeyPrefix = "pid:"
+
+// statsKey returns the key under which entry is aggregated, prefixed with
+// its host when it has one.
+func statsKey(entry *parse.LogEntry, opts options) string {
+	prefix := hostPrefix(entry.Host)
+	switch {
+	case opts.flatten:
+		return prefix + flattenedName
+	case opts.trackByPID && entry.PID > 0:
+		return prefix + pidKeyPrefix + strconv.Itoa(entry.PID)
+	case opts.byPID && entry.PID > 0:
+		return prefix + entry.Name + "#" + strconv.Itoa(entry.PID)
+	}
+	return prefix + entry.Name
+}
+
+// hostPrefix returns the prefix of the stats keys of processes on host.
+// Process names may contain a slash, so the host is not split back out of a
+// key; ProcessStats.Host is read instead.
+func hostPrefix(host string) string {
+	if host == "" {
+		return ""
 	}
+	return host + "/"
+}
+
+// finalizeStats completes the stats map once all input has been read. Stats
+// keyed by PID are renamed to name#pid, using the PID's most common name
+// (the latest one on a tie).
+func finalizeStats(stats map[string]parse.ProcessStats) {
+	for key, stat := range stats {
+		prefix := hostPrefix(stat.Host)
+		pid, ok := strings.CutPrefix(strings.TrimPrefix(key, prefix), pidKeyPrefix)
+		if !ok {
+			continue
+		}
+		name := stat.Name
+		for n, count := range stat.Names {
+			if count > stat.Names[name] || (count == stat.Names[name] && name != stat.Name && n < name) {
+				name = n
+			}
+		}
+		delete(stats, key)
+		stats[prefix+name+"#"+pid] = stat
+	}
+}
 
-	return &LogEntry{
-		Name:      name,
-		State:     state,
-		CPU:       cpu,
-		Memory:    memory,
-		PSS:       pss,
-		Timestamp: timestamp,
-	}, nil
+// maxReportedErrors is how many parse errors a skipReport keeps verbatim.
+const maxReportedErrors = 5
+
+// errTooManyErrors is returned once more lines than --max-errors fail to
+// parse.
+var errTooManyErrors = errors.New("too many malformed lines")
+
+// skipReport counts the lines that failed to parse and keeps the first few
+// errors, with their line numbers, for the report.
+type skipReport struct {
+	Skipped   int
+	First     []string
+	Sanitized int // negative CPU, RSS or PSS values clamped to 0
 }
 
-// updateStats updates the ProcessStats map with the new LogEntry.
-func updateStats(stats map[string]ProcessStats, entry *LogEntry) {
-	tsStr := entry.Timestamp.Format("2006-01-02 15:04:05")
-	stat, exists := stats[entry.Name]
-	if !exists {
-		stat = ProcessStats{
-			State:        entry.State,
-			MinMemory:    entry.Memory,
-			MaxMemory:    entry.Memory,
-			MinPSS:       entry.PSS,
-			MaxPSS:       entry.PSS,
-			MinCPU:       entry.CPU,
-			MaxCPU:       entry.CPU,
-			LatestCPU:    entry.CPU,
-			LatestMemory: entry.Memory,
-			LatestPSS:    entry.PSS,
-			LatestTime:   entry.Timestamp,
+// add records a line that failed to parse.
+func (sr *skipReport) add(source string, lineNo int, err error) {
+	sr.Skipped++
+	if len(sr.First) < maxReportedErrors {
+		msg := fmt.Sprintf("line %d: %v", lineNo, err)
+		if source != "" {
+			msg = source + ": " + msg
 		}
+		sr.First = append(sr.First, msg)
 	}
+}
 
-	// Aggregate
-	stat.TotalCPU += entry.CPU
-	stat.TotalMemory += entry.Memory
-	stat.TotalPSS += entry.PSS
+// processLogs reads log data from an io.Reader and processes each line. It
+// also reports the lines that were skipped because they failed to parse.
+func processLogs(r io.Reader, opts options) (map[string]parse.ProcessStats, *skipReport, error) {
+	if opts.skips == nil {
+		opts.skips = &skipReport{}
+	}
+	stats := make(map[string]parse.ProcessStats)
+	if err := aggregateLogs(stats, r, opts); err != nil {
+		return nil, opts.skips, err
+	}
+	finalizeStats(stats)
+	return stats, opts.skips, nil
+}
 
-	// Min/Max
-	if entry.Memory < stat.MinMemory {
-		stat.MinMemory = entry.Memory
+// aggregateLogs reads log data from an io.Reader and merges each line into an
+// existing stats map.
+func aggregateLogs(stats map[string]parse.ProcessStats, r io.Reader, opts options) error {
+	scanner := newLineScanner(r, opts.inputFormat, opts.maxLineBytes)
+	if opts.limitLines > 0 {
+		scanner = &limitScanner{lineScanner: scanner, left: opts.limitLines}
 	}
-	if entry.Memory > stat.MaxMemory {
-		stat.MaxMemory = entry.Memory
-		stat.MaxMemoryTime = tsStr
+	if err := scanLogs(stats, scanner, opts); err != nil {
+		return err
 	}
-	if entry.PSS < stat.MinPSS {
-		stat.MinPSS = entry.PSS
+	if opts.reorder != nil {
+		for _, entry := range opts.reorder.drain() {
+			if err := applyEntry(stats, entry, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scanLogs merges each line of scanner into stats.
+func scanLogs(stats map[string]parse.ProcessStats, scanner lineScanner, opts options) error {
+	if opts.workers > 1 {
+		return aggregateParallel(stats, scanner, opts)
+	}
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if err := aggregateLine(stats, scanner.Text(), lineNo, opts); err != nil {
+			return err
+		}
 	}
-	if entry.PSS > stat.MaxPSS {
-		stat.MaxPSS = entry.PSS
-		stat.MaxPSSTime = tsStr
+	return scanner.Err()
+}
+
+// aggregateLine parses a single log line and merges it into stats. Lines
+// that fail to parse are recorded and skipped; an error is only returned
+// once more than --max-errors lines were skipped.
+func aggregateLine(stats map[string]parse.ProcessStats, line string, lineNo int, opts options) error {
+	entry, err := opts.parseLine(line)
+	return mergeEntry(stats, line, lineNo, entry, err, opts)
+}
+
+// parseLine parses line with the configured parser. Surrounding whitespace,
+// including the \r of CRLF line endings, is trimmed first.
+func (opts options) parseLine(line string) (*parse.LogEntry, error) {
+	if opts.maxLineBytes > 0 && len(line) > opts.maxLineBytes {
+		return nil, fmt.Errorf("line longer than --max-line-bytes %d", opts.maxLineBytes)
 	}
-	if entry.CPU < stat.MinCPU {
-		stat.MinCPU = entry.CPU
+	line = strings.TrimSpace(line)
+	parseFn := parse.ParseLogEntry
+	if opts.parse != nil {
+		parseFn = opts.parse
 	}
-	if entry.CPU > stat.MaxCPU {
-		stat.MaxCPU = entry.CPU
-		stat.MaxCPUTime = tsStr
+	entry, err := parseFn(line)
+	if err == nil && opts.noNegative {
+		if label := negativeMetric(entry); label != "" {
+			return nil, fmt.Errorf("negative %s value", label)
+		}
 	}
+	return entry, err
+}
 
-	// Latest
-	if entry.Timestamp.After(stat.LatestTime) {
-		stat.LatestCPU = entry.CPU
-		stat.LatestMemory = entry.Memory
-		stat.LatestPSS = entry.PSS
-		stat.LatestTime = entry.Timestamp
+// negativeMetric returns the label of the first negative CPU, RSS or PSS
+// value of entry, or "" if there is none.
+func negativeMetric(entry *parse.LogEntry) string {
+	switch {
+	case entry.CPU < 0:
+		return "CPU"
+	case entry.Memory < 0:
+		return "RSS"
+	case entry.PSS < 0:
+		return "PSS"
 	}
+	return ""
+}
 
-	stat.Count++
-	stats[entry.Name] = stat
+// clampNegative sets the negative CPU, RSS and PSS values of entry, which a
+// miscalibrated collector can emit, to 0 and returns how many it changed.
+func clampNegative(entry *parse.LogEntry) int {
+	n := 0
+	for _, v := range []*float64{&entry.CPU, &entry.Memory, &entry.PSS} {
+		if *v < 0 {
+			*v = 0
+			n++
+		}
+	}
+	return n
 }
 
-// processLogs reads log data from an io.Reader and processes each line.
-func processLogs(r io.Reader) (map[string]ProcessStats, error) {
-	stats := make(map[string]ProcessStats)
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		entry, err := parseLogEntry(line)
-		if err != nil {
-			continue
+// mergeEntry merges the result of parsing line into stats.
+func mergeEntry(stats map[string]parse.ProcessStats, line string, lineNo int, entry *parse.LogEntry, err error, opts options) error {
+	if opts.coverage != nil {
+		opts.coverage.add(line)
+	}
+	if err != nil {
+		if opts.errLog != nil {
+			if opts.source != "" {
+				_, _ = fmt.Fprintf(opts.errLog, "%s: ", opts.source)
+			}
+			_, _ = fmt.Fprintf(opts.errLog, "line %d: %v: %q\n", lineNo, err, line)
+		}
+		if opts.skips != nil {
+			opts.skips.add(opts.source, lineNo, err)
+			if opts.maxErrors >= 0 && opts.skips.Skipped > opts.maxErrors {
+				return fmt.Errorf("%w: %d exceeds --max-errors %d", errTooManyErrors, opts.skips.Skipped, opts.maxErrors)
+			}
+		}
+		return nil
+	}
+	if opts.reorder == nil {
+		return applyEntry(stats, entry, opts)
+	}
+	for _, ready := range opts.reorder.push(entry) {
+		if err := applyEntry(stats, ready, opts); err != nil {
+			return err
 		}
-		updateStats(stats, entry)
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	return nil
+}
+
+// applyEntry aggregates a parsed entry into stats unless it is filtered out.
+func applyEntry(stats map[string]parse.ProcessStats, entry *parse.LogEntry, opts options) error {
+	if opts.process != "" && entry.Name != opts.process {
+		return nil
+	}
+	if (!opts.since.IsZero() && entry.Timestamp.Before(opts.since)) || (!opts.until.IsZero() && entry.Timestamp.After(opts.until)) {
+		return nil
+	}
+	if opts.filter != nil && !opts.filter.MatchString(entry.Name) {
+		return nil
+	}
+	// The denylist wins over the allowlist.
+	if opts.exclude.matches(entry.Name) || (opts.include != nil && !opts.include.matches(entry.Name)) {
+		return nil
 	}
-	return stats, nil
+	if entry.Host == "" {
+		entry.Host = opts.host
+	}
+	if n := clampNegative(entry); n > 0 && opts.skips != nil {
+		opts.skips.Sanitized += n
+	}
+	if opts.cpuFraction {
+		entry.CPU *= 100
+	}
+	if opts.sqlite != nil {
+		if err := opts.sqlite.add(entry); err != nil {
+			return err
+		}
+	}
+	if opts.namePattern != nil {
+		entry.Name = normalizeName(opts.namePattern, entry.Name)
+	}
+	key := statsKey(entry, opts)
+	parse.Update(stats, key, entry, parse.Options{
+		EWMAAlpha:     opts.ewmaAlpha,
+		TrackNames:    opts.trackByPID,
+		RetainSamples: opts.retainSamples,
+		Reservoir:     opts.percentileMode == percentileApprox,
+		Rand:          opts.rng,
+		Dedup:         opts.dedup,
+	})
+	if opts.keys != nil {
+		if err := opts.keys.touch(stats, key, flushEvicted(opts)); err != nil {
+			return err
+		}
+	}
+	if opts.ndjson != nil {
+		return writeSampleNDJSON(opts.ndjson, entry)
+	}
+	return nil
+}
+
+// formatUptime renders an uptime in seconds as a duration, e.g. "2h5m0s".
+func formatUptime(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
+
+// signed prefixes formatted, the rendering of v, with + unless v is
+// negative.
+func signed(v float64, formatted string) string {
+	if v < 0 {
+		return formatted
+	}
+	return "+" + formatted
+}
+
+// formatRatio renders rss/pss, e.g. "4.00". A high ratio means most of the
+// RSS is shared and would not be freed by killing the process; a ratio near
+// 1 means it is private. Without a PSS reading there is no ratio.
+func formatRatio(rss, pss float64, precision int, rounding string) string {
+	if pss <= 0 {
+		return "n/a"
+	}
+	return formatFloat(rss/pss, precision, rounding)
+}
+
+// formatSpread renders a standard deviation, formatted by format, with its
+// coefficient of variation, e.g. "1.20 MB (CV 0.05)".
+func formatSpread(s parse.Welford, format func(float64) string) string {
+	out := format(s.StdDev())
+	if cv := s.CV(); !math.IsNaN(cv) {
+		out += fmt.Sprintf(" (CV %.2f)", cv)
+	}
+	return out
+}
+
+// plural formats n with noun, adding an s unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+// countTrue returns how many of flags are set.
+func countTrue(flags ...bool) int {
+	n := 0
+	for _, f := range flags {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+// looksFractional reports whether the CPU values in stats look like 0–1
+// fractions: at least one sample is non-zero but none exceeds 1.0.
+func looksFractional(stats map[string]parse.ProcessStats) bool {
+	nonZero := false
+	for _, stat := range stats {
+		if stat.MaxCPU > 1.0 {
+			return false
+		}
+		if stat.MaxCPU > 0 {
+			nonZero = true
+		}
+	}
+	return nonZero
 }
 
 // printStats outputs the process statistics in a formatted way.
-func printStats(stats map[string]ProcessStats) {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	for name, stat := range stats {
+func printStats(out io.Writer, stats map[string]parse.ProcessStats, opts options) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	mem := unitLabel(opts.unit)
+	names := sortedNames(stats, opts.sortBy)
+	grouped := hasHosts(stats)
+	if grouped {
+		// Keep the --sort order within each host.
+		sort.SliceStable(names, func(i, j int) bool { return stats[names[i]].Host < stats[names[j]].Host })
+	}
+	for i, name := range names {
+		stat := stats[name]
+		label := name
+		if grouped {
+			if i == 0 || stat.Host != stats[names[i-1]].Host {
+				host := stat.Host
+				if host == "" {
+					host = "(unknown)"
+				}
+				_, _ = fmt.Fprintf(w, "Host %s:\n", host)
+			}
+			label = strings.TrimPrefix(name, hostPrefix(stat.Host))
+		}
 		avgCPU := stat.TotalCPU / float64(stat.Count)
 		avgMem := stat.TotalMemory / float64(stat.Count)
 		avgPSS := stat.TotalPSS / float64(stat.Count)
+		avgVSZ := stat.TotalVSZ / float64(stat.Count)
 		latestTimeStr := stat.LatestTime.Format("2006-01-02 15:04:05")
 
-		_, _ = fmt.Fprintf(w, "Process %s:\n", name)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "State:", stat.State)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f%%\n", "Avg CPU Usage:", avgCPU)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f%%\n", "Min CPU Usage:", stat.MinCPU)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f%% (At: %s)\n", "Max CPU Usage:", stat.MaxCPU, stat.MaxCPUTime)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f%% (Latest: %s)\n", "Latest CPU Usage:", stat.LatestCPU, latestTimeStr)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f MB\n", "Avg RSS (MB):", avgMem)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f MB\n", "Min RSS (MB):", stat.MinMemory)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f MB (At: %s)\n", "Max RSS (MB):", stat.MaxMemory, stat.MaxMemoryTime)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f MB (Latest: %s)\n", "Latest RSS (MB):", stat.LatestMemory, latestTimeStr)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f MB\n", "Avg PSS (MB):", avgPSS)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f MB\n", "Min PSS (MB):", stat.MinPSS)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f MB (At: %s)\n", "Max PSS (MB):", stat.MaxPSS, stat.MaxPSSTime)
-		_, _ = fmt.Fprintf(w, "  %-22s\t%.2f MB (Latest: %s)\n", "Latest PSS (MB):", stat.LatestPSS, latestTimeStr)
+		_, _ = fmt.Fprintf(w, "Process %s:\n", label)
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "State:", formatState(stat))
+		if stat.LatestUptime >= 0 {
+			// After a restart the latest uptime is lower than the max.
+			note := plural(stat.Restarts, "restart")
+			if stat.LatestUptime < opts.minUptime.Seconds() {
+				note += ", below --min-uptime"
+			}
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s (%s)\n", "Uptime:", formatUptime(stat.LatestUptime), note)
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s – %s\n", "Uptime Range:", formatUptime(stat.MinUptime), formatUptime(stat.MaxUptime))
+		}
+		window := stat.LatestTime.Sub(stat.FirstTime)
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (%s)\n", "Observation Window:", window.Round(time.Second), plural(stat.Count, "sample"))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "First Seen:", stat.FirstTime.Format("2006-01-02 15:04:05"))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Last Seen:", latestTimeStr)
+		pids := strconv.Itoa(stat.DistinctPIDs)
+		if stat.DistinctPIDs > 1 {
+			pids += " (restarted or forked)"
+		}
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Distinct PIDs:", pids)
+		if stat.Duplicates > 0 {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%d\n", "Duplicates Dropped:", stat.Duplicates)
+		}
+		if stat.Count > 1 {
+			interval := window / time.Duration(stat.Count-1)
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Avg Sample Interval:", interval.Round(time.Millisecond))
+		}
+		if len(stat.Names) > 1 {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Names:", formatNames(stat.Names))
+		}
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Avg CPU Usage:", opts.color.cpu(avgCPU, formatPercent(avgCPU, opts.precision, opts.rounding)))
+		if opts.ewmaAlpha > 0 {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Smoothed CPU:", formatPercent(stat.EwmaCPU, opts.precision, opts.rounding))
+		}
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Min CPU Usage:", formatPercent(stat.MinCPU, opts.precision, opts.rounding))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (At: %s)\n", "Max CPU Usage:", opts.color.cpu(stat.MaxCPU, formatPercent(stat.MaxCPU, opts.precision, opts.rounding)), stat.MaxCPUTime)
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (Latest: %s)\n", "Latest CPU Usage:", opts.color.cpu(stat.LatestCPU, formatPercent(stat.LatestCPU, opts.precision, opts.rounding)), latestTimeStr)
+		cpuRate, rssRate, hasRate := stat.Velocity()
+		if hasRate {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s/min\n", "CPU Δ:", signed(cpuRate, formatPercent(cpuRate, opts.precision, opts.rounding)))
+		}
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "CPU Std Dev:", formatSpread(stat.SpreadCPU, func(v float64) string { return formatPercent(v, opts.precision, opts.rounding) }))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Avg RSS"+mem+":", formatMem(avgMem, opts.unit, opts.precision, opts.rounding))
+		if opts.ewmaAlpha > 0 {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Smoothed RSS"+mem+":", formatMem(stat.EwmaMemory, opts.unit, opts.precision, opts.rounding))
+		}
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Min RSS"+mem+":", formatMem(stat.MinMemory, opts.unit, opts.precision, opts.rounding))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (At: %s)\n", "Max RSS"+mem+":", opts.color.spike(stat.MaxMemory, avgMem, formatMem(stat.MaxMemory, opts.unit, opts.precision, opts.rounding)), stat.MaxMemoryTime)
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (Latest: %s)\n", "Latest RSS"+mem+":", formatMem(stat.LatestMemory, opts.unit, opts.precision, opts.rounding), latestTimeStr)
+		if hasRate {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s/min\n", "RSS Δ:", signed(rssRate, formatMem(rssRate, opts.unit, opts.precision, opts.rounding)))
+		}
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "RSS Std Dev:", formatSpread(stat.SpreadMemory, memFormatter(opts.unit, opts.precision, opts.rounding)))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Avg PSS"+mem+":", formatMem(avgPSS, opts.unit, opts.precision, opts.rounding))
+		if opts.ewmaAlpha > 0 {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Smoothed PSS"+mem+":", formatMem(stat.EwmaPSS, opts.unit, opts.precision, opts.rounding))
+		}
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Min PSS"+mem+":", formatMem(stat.MinPSS, opts.unit, opts.precision, opts.rounding))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (At: %s)\n", "Max PSS"+mem+":", opts.color.spike(stat.MaxPSS, avgPSS, formatMem(stat.MaxPSS, opts.unit, opts.precision, opts.rounding)), stat.MaxPSSTime)
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (Latest: %s)\n", "Latest PSS"+mem+":", formatMem(stat.LatestPSS, opts.unit, opts.precision, opts.rounding), latestTimeStr)
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "PSS Std Dev:", formatSpread(stat.SpreadPSS, memFormatter(opts.unit, opts.precision, opts.rounding)))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s latest, %s avg\n", "RSS/PSS Ratio:",
+			formatRatio(stat.LatestMemory, stat.LatestPSS, opts.precision, opts.rounding), formatRatio(avgMem, avgPSS, opts.precision, opts.rounding))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Avg VSZ"+mem+":", formatMem(avgVSZ, opts.unit, opts.precision, opts.rounding))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Min VSZ"+mem+":", formatMem(stat.MinVSZ, opts.unit, opts.precision, opts.rounding))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (At: %s)\n", "Max VSZ"+mem+":", formatMem(stat.MaxVSZ, opts.unit, opts.precision, opts.rounding), stat.MaxVSZTime)
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (Latest: %s)\n", "Latest VSZ"+mem+":", formatMem(stat.LatestVSZ, opts.unit, opts.precision, opts.rounding), latestTimeStr)
+		if stat.ThreadSamples > 0 {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Avg Threads:", formatFloat(float64(stat.TotalThreads)/float64(stat.ThreadSamples), opts.precision, opts.rounding))
+			_, _ = fmt.Fprintf(w, "  %-22s\t%d\n", "Min Threads:", stat.MinThreads)
+			_, _ = fmt.Fprintf(w, "  %-22s\t%d\n", "Max Threads:", stat.MaxThreads)
+		}
+		_, _ = fmt.Fprintf(w, "  %-22s\t%.1fx\n", "CPU peak/avg:", peakRatio(stat.MaxCPU, avgCPU))
+		_, _ = fmt.Fprintf(w, "  %-22s\t%.1fx\n", "RSS peak/avg:", peakRatio(stat.MaxMemory, avgMem))
+		if len(opts.events) > 0 {
+			peaks := []struct {
+				label string
+				at    time.Time
+			}{
+				{"Max CPU event:", stat.MaxCPUAt},
+				{"Max RSS event:", stat.MaxMemoryAt},
+				{"Max PSS event:", stat.MaxPSSAt},
+			}
+			for _, p := range peaks {
+				if note := peakEventNote(opts.events, p.at, opts.eventWindow); note != "" {
+					_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", p.label, note)
+				}
+			}
+		}
+		if opts.percentiles {
+			dist := distributionSamples(stat)
+			cpu := func(v float64) string { return formatPercent(v, opts.precision, opts.rounding) }
+			memory := memFormatter(opts.unit, opts.precision, opts.rounding)
+			for _, m := range []struct {
+				label  string
+				format func(float64) string
+				value  func(parse.Sample) float64
+			}{
+				{"CPU p50/p95/p99:", cpu, sampleCPU},
+				{"RSS p50/p95/p99:", memory, sampleRSS},
+				{"PSS p50/p95/p99:", memory, samplePSS},
+			} {
+				values := sampleValues(dist, m.value)
+				p := func(q float64) string { return m.format(percentile(values, q)) }
+				_, _ = fmt.Fprintf(w, "  %-22s\t%s / %s / %s\n", m.label, p(50), p(95), p(99))
+			}
+		}
+		if opts.burstiness {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%.2f\n", "CPU Above Avg Ratio:", burstiness(stat))
+		}
+		if opts.stabilityBand > 0 {
+			// The baseline was validated at startup.
+			baseline, _ := stabilityBaseline(stat, opts.baselineMetric)
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s within ±%g%% of %s (%s)\n", "RSS Stability:",
+				formatPercent(stability(stat.Samples, baseline, opts.stabilityBand), opts.precision, opts.rounding),
+				opts.stabilityBand, opts.baselineMetric, formatMem(baseline, opts.unit, opts.precision, opts.rounding))
+		}
+		if opts.detectLeaks {
+			slope, r2 := memoryTrend(rssPoints(sortedByTime(distributionSamples(stat))))
+			line := fmt.Sprintf("%+.2f MB/h (R² %.2f)", slope, r2)
+			if isLeaking(slope, r2, opts) {
+				line += " — possible leak"
+			}
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "RSS Trend:", line)
+		}
+		if opts.projectTo > 0 {
+			slope, _ := memoryTrend(rssPoints(sortedByTime(stat.Samples)))
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "RSS Projection:", projectMemory(stat.LatestMemory, slope, opts.projectTo))
+		}
+		if opts.cpuThreshold > 0 {
+			spikes := cpuSpikes(stat.Samples, opts.cpuThreshold, opts.cpuDuration)
+			_, _ = fmt.Fprintf(w, "  %-22s\t%d above %.2f%% for %s or longer\n", "CPU Spikes:", len(spikes), opts.cpuThreshold, opts.cpuDuration)
+			for _, s := range spikes {
+				_, _ = fmt.Fprintf(w, "  %-22s\t%s to %s, peak %s\n", "", s.Start.Format("2006-01-02 15:04:05"), s.End.Format("15:04:05"), formatPercent(s.Peak, opts.precision, opts.rounding))
+			}
+		}
+		if opts.zscore > 0 {
+			for _, a := range latestAnomalies(stat, opts.zscore) {
+				_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Anomaly:", a)
+			}
+		}
+		if opts.cpuSeconds {
+			maxStep := gapThreshold(sortedByTime(stat.Samples), opts.gapFactor, opts.maxGap)
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "CPU-seconds:", formatFloat(cpuSeconds(stat.Samples, maxStep), opts.precision, opts.rounding))
+		}
+		if opts.gaps {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Sampling Gaps:", formatGaps(findGaps(stat.Samples, opts.gapFactor, opts.maxGap)))
+		}
+		if opts.sparkline {
+			rss := sampleValues(sortedByTime(stat.Samples), sampleRSS)
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "RSS Sparkline:", sparkline(rss, timelineWidth))
+		}
+		if opts.histogram != "" {
+			format := memFormatter(opts.unit, opts.precision, opts.rounding)
+			if opts.histogram == "cpu" {
+				format = func(v float64) string { return formatPercent(v, opts.precision, opts.rounding) }
+			}
+			buckets, err := histogram(sampleValues(stat.Samples, histogramMetrics[opts.histogram]), opts.bucketWidth)
+			if err != nil {
+				_, _ = fmt.Fprintf(w, "  %s Histogram:\t%v\n", strings.ToUpper(opts.histogram), err)
+			} else {
+				_, _ = fmt.Fprintf(w, "  %s Histogram:\n", strings.ToUpper(opts.histogram))
+				printHistogram(w, buckets, format)
+			}
+		}
+		if opts.stateTimeline {
+			_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "State timeline:", stateTimeline(stat.Samples, timelineWidth))
+		}
+		// Keys may carry a host or PID, so requests are matched by name.
+		if n, ok := opts.requests[stat.Name]; ok {
+			maxStep := gapThreshold(sortedByTime(stat.Samples), opts.gapFactor, opts.maxGap)
+			cpuMs := cpuSeconds(stat.Samples, maxStep) * 1000 / n
+			rssKB := stat.MaxMemory * 1024 / n
+			_, _ = fmt.Fprintf(w, "  %-22s\t~%.2fms CPU/request, ~%.1fKB RSS/request\n", "Cost per request:", cpuMs, rssKB)
+		}
 		_, _ = fmt.Fprintln(w)
 	}
 	_ = w.Flush()
+	if !opts.noSummary {
+		printReportSummary(out, stats, opts)
+	}
+}
+
+// printReportSummary writes the footer of the text report, totalling stats.
+func printReportSummary(out io.Writer, stats map[string]parse.ProcessStats, opts options) {
+	if len(stats) == 0 {
+		return
+	}
+	s := summarize(stats)
+	if opts.skips != nil {
+		s.Sanitized = opts.skips.Sanitized
+	}
+	printSummary(out, s, opts.unit, opts.precision, opts.rounding)
+}
+
+// hasHosts reports whether any process in stats was logged with a host.
+func hasHosts(stats map[string]parse.ProcessStats) bool {
+	for _, stat := range stats {
+		if stat.Host != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// printMemoryPressure prints the share of host memory taken by the latest RSS
+// of every process.
+func printMemoryPressure(out io.Writer, stats map[string]parse.ProcessStats, opts options) {
+	used := totalLatestRSS(stats)
+	pct := used / opts.totalMemory * 100
+	line := fmt.Sprintf("System memory: %.1f/%.1fGB (%.0f%%)", used/1024, opts.totalMemory/1024, pct)
+	if pct >= opts.oomThreshold {
+		line += " — approaching OOM"
+	}
+	_, _ = fmt.Fprintln(out, line)
+}
+
+// totalLatestRSS sums the latest RSS in MB across all processes.
+func totalLatestRSS(stats map[string]parse.ProcessStats) float64 {
+	total := 0.0
+	for _, stat := range stats {
+		total += stat.LatestMemory
+	}
+	return total
+}
+
+// formatNames lists the names a process was seen under with their sample
+// counts, most common first.
+func formatNames(names map[string]int) string {
+	list := make([]string, 0, len(names))
+	for n := range names {
+		list = append(list, n)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if names[list[i]] != names[list[j]] {
+			return names[list[i]] > names[list[j]]
+		}
+		return list[i] < list[j]
+	})
+	for i, n := range list {
+		list[i] = fmt.Sprintf("%s (%d)", n, names[n])
+	}
+	return strings.Join(list, ", ")
 }
 
 func main() {
-	var reader io.Reader
+	os.Exit(run(os.Args[1:]))
+}
 
-	// If a file path is provided as an argument, use it.
-	if len(os.Args) > 1 {
-		file, err := os.Open(os.Args[1])
+// runCommand parses the arguments of the report, diff, validate or serve
+// subcommand, aggregates the selected input and reports the result. It
+// returns the process exit code.
+func runCommand(name string, args []string) int {
+	// Every flag is defined on defs; fs only gets the ones name accepts.
+	defs := flag.NewFlagSet("sauronlens", flag.ContinueOnError)
+	opts := options{
+		skips:        &skipReport{},
+		requests:     requestCounts{},
+		exitCodes:    exitCodes{},
+		exitPriority: defaultExitPriority,
+	}
+	listenURL := defs.String("listen", "", "aggregate log lines from the first connection to tcp://host:port or unix:///path, like stdin")
+	keepListening := defs.Bool("keep-listening", false, "with --listen, keep accepting connections until interrupted instead of stopping after the first")
+	listenUnix := defs.String("listen-unix", "", "aggregate log lines received on a Unix domain socket at this path")
+	defs.BoolVar(&opts.validate, "validate", false, "require every line to have exactly the Sauron fields in order and exit 1 if any does not")
+	coverage := defs.Bool("coverage", false, "report the share of lines with a valid value for each log field")
+	fieldSep := defs.String("field-sep", parse.DefaultFormat.FieldSep, "separator between the fields of a log line")
+	kvSep := defs.String("kv-sep", parse.DefaultFormat.KVSep, "separator between a field's key and its value")
+	defs.StringVar(&opts.host, "host", "", "host to group processes under when the log has no Host field")
+	defs.BoolVar(&opts.noNegative, "no-negative", false, "skip lines with a negative CPU, RSS or PSS as malformed instead of clamping the value to 0")
+	lenient := defs.Bool("lenient", false, "parse lines missing RSS, VSZ, PSS or CPU, reading the missing metric as 0")
+	timeLayout := defs.String("time-layout", "", "Go time layout of the timestamp field (default: detect RFC3339 or \"2006-01-02 15:04:05\")")
+	pattern := defs.String("regex", "", "parse lines with this regular expression; named groups name, cpu, rss and timestamp are required, pid, state, threads, vsz, pss and uptime optional")
+	defs.BoolVar(&opts.cpuFraction, "cpu-fraction", false, "treat the CPU field as a 0–1 fraction and convert it to a percent")
+	defs.IntVar(&opts.maxErrors, "max-errors", -1, "fail once more than this many lines are malformed (-1 for no limit)")
+	sqlitePath := defs.String("sqlite", "", "also insert every aggregated entry into this SQLite database")
+	defs.IntVar(&opts.maxLineBytes, "max-line-bytes", bufio.MaxScanTokenSize, "skip lines longer than this many bytes as malformed")
+	defs.IntVar(&opts.limitLines, "limit-lines", 0, "read only the first N lines of each input, counting malformed ones (0 reads everything)")
+	ndjson := defs.Bool("ndjson", false, "with --follow, write each new sample as a JSON line instead of reprinting the report")
+	reorderWindow := defs.Duration("reorder-window", 0, "aggregate each input in timestamp order, sorting lines up to this far out of order (e.g. 5s)")
+	recursive := defs.Bool("recursive", false, "also read log files in subdirectories of directory arguments")
+	quiet := defs.Bool("quiet", false, "do not show reading progress on stderr (shown only when stderr is a terminal)")
+	followFlag := defs.Bool("follow", false, "keep reading lines appended to the log file, reprinting the report until interrupted")
+	serveAddr := defs.String("serve", "", "serve the stats as JSON at /stats on this address (e.g. :8080) until interrupted; combine with --follow for live stats")
+	watchInterval := defs.Duration("watch", 0, "re-read the log files from scratch and redraw the report at this interval until interrupted")
+	followInterval := defs.Duration("follow-interval", 10*time.Second, "with --follow, how often to reprint the report")
+	defs.BoolVar(&opts.dedup, "dedup", false, "ignore a line with the same name and timestamp as the process's latest one")
+	defs.IntVar(&opts.workers, "workers", runtime.GOMAXPROCS(0), "number of goroutines parsing log lines")
+	strict := defs.Bool("strict", false, "stop at the first log file that cannot be read instead of skipping it")
+	output := defs.String("output", "", "write the report to this file instead of stdout")
+	errorsOut := defs.String("errors-out", "", "write lines that fail to parse, with line number and reason, to this file")
+	defs.Float64Var(&opts.alertP95CPU, "alert-p95-cpu", 0, "exit non-zero when a process's p95 CPU usage exceeds this percent")
+	defs.IntVar(&opts.failOnZombies, "fail-on-zombies", 0, "exit non-zero when at least `N` processes are zombies in their latest sample")
+	defs.Float64Var(&opts.alertGrowth, "alert-rss-growth", 0, "exit 1 when a process's RSS trend grows faster than this many MB/hour (with an R² of at least --leak-r2)")
+	defs.BoolVar(&opts.flatten, "flatten", false, "pool every sample into a single aggregate instead of per-process stats")
+	defs.Var(opts.requests, "requests", "name:count of requests served, for per-request cost estimates (repeatable)")
+	defs.BoolVar(&opts.byState, "by-state", false, "also summarize process counts and total RSS per latest state")
+	defs.BoolVar(&opts.byPID, "by-pid", false, "aggregate each PID separately, reported as name#pid")
+	defs.BoolVar(&opts.trackByPID, "track-by-pid-then-name", false, "aggregate by PID so renamed processes stay together, labelled with their most common name")
+	namePattern := defs.String("name-pattern", "", "aggregate processes under the first capture group of this regexp, e.g. '^(worker)-\\d+$'; names that do not match are kept")
+	includeFile := defs.String("include-file", "", "only aggregate processes named, or matching a glob, on a line of this file (# starts a comment)")
+	excludeFile := defs.String("exclude-file", "", "never aggregate processes named, or matching a glob, on a line of this file; wins over --include-file")
+	filter := defs.String("filter", "", "only aggregate processes whose name matches this case-insensitive regexp; wrap in ^...$ for a case-sensitive exact match")
+	defs.StringVar(&opts.process, "process", "", "only aggregate the process with this exact name")
+	trace := defs.Bool("trace", false, "print every sample of --process, one line each, instead of the report")
+	since := defs.String("since", "", "only aggregate samples at or after this RFC3339 time, or a duration before now such as -30m")
+	until := defs.String("until", "", "only aggregate samples at or before this RFC3339 time, or a duration before now such as -5m")
+	jsonOut := defs.Bool("json", false, "print the stats as JSON instead of the text report")
+	csvOut := defs.Bool("csv", false, "print the stats as CSV instead of the text report")
+	markdownOut := defs.Bool("markdown", false, "print the stats as a Markdown table instead of the text report")
+	defs.StringVar(&opts.rounding, "rounding", roundHalfEven, "how metrics halfway between two reported values round: half-even or half-up")
+	maxKeys := defs.Int("max-keys", 0, "hold at most `N` processes, reporting and evicting the least recently updated ones as input is read (0: unlimited)")
+	templateText := defs.String("template", "", "print each process with this Go text/template instead of the text report")
+	templateFile := defs.String("template-file", "", "read the --template from `path`")
+	compactOut := defs.Bool("compact", false, "print one aligned line per process with its latest state, CPU, RSS and PSS and its sample count")
+	promOut := defs.Bool("prometheus", false, "print the latest metrics in the Prometheus text exposition format instead of the text report")
+	colorMode := defs.String("color", colorAuto, "highlight high CPU and memory spikes: auto (when stdout is a terminal), always or never")
+	defs.Float64Var(&opts.color.cpuPercent, "color-cpu", 80, "with --color, CPU percent above which values are red")
+	defs.Float64Var(&opts.color.spikeRatio, "color-spike", 2, "with --color, peak/avg memory ratio at which peaks are yellow")
+	defs.StringVar(&opts.unit, "unit", unitMB, "unit memory is printed in: mb, gb, or auto to pick one per value")
+	defs.IntVar(&opts.precision, "precision", defaultPrecision, "decimal places of reported metrics in text, CSV, JSON and Markdown output (0–9)")
+	defs.BoolVar(&opts.noSummary, "no-summary", false, "leave out the totals footer of the text report")
+	defs.IntVar(&opts.top, "top", 0, "only report the first N processes in --sort order (0 for all)")
+	defs.StringVar(&opts.sortBy, "sort", sortName, "order processes by name, or highest first by latest cpu, rss, pss or peak-ratio (RSS peak/avg)")
+	get := defs.String("get", "", "print only the value of a process.metric selector, e.g. nginx.max_rss")
+	defs.BoolVar(&opts.stateTimeline, "state-timeline", false, "print each process's state over time as a compact timeline (e.g. SSSRRDSS)")
+	defs.BoolVar(&opts.sparkline, "sparkline", false, "print each process's RSS over time as a block sparkline")
+	defs.StringVar(&opts.histogram, "histogram", "", "print each process's distribution of cpu, rss or pss as a text histogram")
+	defs.Float64Var(&opts.bucketWidth, "bucket-width", 10, "with --histogram, the width of each bucket in MB or percent")
+	defs.Float64Var(&opts.zscore, "zscore", 0, "flag latest CPU, RSS or PSS readings more than this many standard deviations from the process's mean (0 disables)")
+	defs.DurationVar(&opts.minUptime, "min-uptime", 0, "flag processes whose latest uptime is below this, i.e. that just started or restarted")
+	defs.BoolVar(&opts.cpuSeconds, "cpu-seconds", false, "print each process's approximate CPU time over the window, skipping gaps per --gap-factor/--max-gap")
+	defs.BoolVar(&opts.burstiness, "burstiness", false, "print the fraction of samples where CPU exceeded the process's average")
+	defs.BoolVar(&opts.percentiles, "percentiles", false, "report p50, p95 and p99 of CPU, RSS and PSS")
+	defs.StringVar(&opts.percentileMode, "percentile-mode", percentileExact, "exact (keep every sample) or approx (bounded reservoir sample per process)")
+	defs.Float64Var(&opts.ewmaAlpha, "ewma", 0, "report an exponentially-weighted moving average with this alpha (0–1]; assumes lines are in time order")
+	defs.Float64Var(&opts.ewmaAlpha, "ewma-alpha", 0, "alias for --ewma")
+	defs.DurationVar(&opts.resampleStep, "resample", 0, "write each process's series interpolated onto a regular grid of this step to --out")
+	defs.Float64Var(&opts.cpuThreshold, "cpu-threshold", 0, "report sustained CPU spikes above this percent (0 disables)")
+	defs.DurationVar(&opts.cpuDuration, "cpu-duration", time.Minute, "with --cpu-threshold, how long CPU must stay above it to count as a spike")
+	defs.BoolVar(&opts.gaps, "gaps", false, "report gaps in sampling wider than --gap-factor times the median interval")
+	defs.Float64Var(&opts.gapFactor, "gap-factor", 5, "with --gaps, how many median intervals count as a gap")
+	defs.DurationVar(&opts.maxGap, "max-gap", 0, "report sampling gaps wider than this, overriding --gap-factor (implies --gaps)")
+	defs.DurationVar(&opts.resampleMaxGap, "resample-max-gap", 0, "do not interpolate across gaps wider than this (default 3x --resample)")
+	out := defs.String("out", "", "file for series output such as --resample")
+	defs.Var((*sizeFlag)(&opts.projectTo), "project-to", "project when each process's RSS reaches this size (e.g. 512MB) at its current growth rate")
+	defs.Float64Var(&opts.stabilityBand, "stability-band", 0, "report the share of samples whose RSS stays within this percent of the baseline")
+	defs.StringVar(&opts.baselineMetric, "baseline-metric", "first", "stability baseline: first, mean or a size such as 512MB")
+	defs.BoolVar(&opts.detectLeaks, "detect-leaks", false, "report each process's RSS trend and flag steady growth as a possible leak, exiting 10")
+	defs.Float64Var(&opts.leakSlope, "leak-slope", 1, "with --detect-leaks, the RSS growth in MB/hour above which a trend is flagged")
+	defs.Float64Var(&opts.leakR2, "leak-r2", 0.8, "with --detect-leaks, the minimum R² for a trend to count as real")
+	outliers := defs.Bool("outliers", false, "flag processes whose RSS is far above the median of their group (e.g. worker-N)")
+	outlierFactor := defs.Float64("outlier-factor", 2, "with --outliers, the multiple of the group median RSS that counts as an outlier")
+	eventsFile := defs.String("events", "", "CSV of timestamp,label events to correlate with peak usage")
+	defs.DurationVar(&opts.eventWindow, "event-window", 5*time.Minute, "how close a peak must be to an event to be reported")
+	defs.Var((*sizeFlag)(&opts.totalMemory), "total-memory", "host memory size (e.g. 16GB) for OOM-risk reporting")
+	defs.Float64Var(&opts.oomThreshold, "oom-threshold", 90, "percent of --total-memory at which to warn of OOM risk")
+	defs.StringVar(&opts.inputFormat, "input-format", inputLines, "input encoding: lines or framed (4-byte big-endian length prefix per line)")
+	defs.StringVar(&opts.alertsNDJSON, "alerts-ndjson", "", "also write alerts as newline-delimited JSON to this file (\"-\" for stderr)")
+	defs.Var(opts.exitCodes, "exit-code", "category=code exit code override for an alert category (repeatable)")
+	exitPriority := defs.String("exit-priority", "", "comma-separated alert categories, most urgent first, deciding the exit code when several fire")
+	fs := commandFlagSet(name, defs)
+	_ = fs.Parse(args) // ExitOnError exits on failure
+	if name == cmdValidate {
+		opts.validate = true
+	}
+	if name == cmdServe && *serveAddr == "" {
+		*serveAddr = defaultServeAddr
+	}
+	// "sauronlens [flags] diff a b" predates the subcommands.
+	diffArgs := fs.Args()
+	if name == cmdReport && fs.Arg(0) == cmdDiff {
+		name, diffArgs = cmdDiff, fs.Args()[1:]
+		// The flags were parsed as report flags; hold them to diff's.
+		var unsupported []string
+		fs.Visit(func(f *flag.Flag) {
+			if !acceptsFlag(cmdDiff, f.Name) {
+				unsupported = append(unsupported, "-"+f.Name)
+			}
+		})
+		if len(unsupported) > 0 {
+			fmt.Fprintf(os.Stderr, "flag provided but not defined for diff: %s\n", strings.Join(unsupported, ", "))
+			return 2
+		}
+	}
+
+	if *filter != "" {
+		re, err := compileFilter(*filter)
 		if err != nil {
-			fmt.Println("Error opening file:", err)
-			return
+			fmt.Println("Error: invalid --filter:", err)
+			return 1
+		}
+		opts.filter = re
+	}
+
+	if *namePattern != "" {
+		re, err := regexp.Compile(*namePattern)
+		if err == nil && re.NumSubexp() == 0 {
+			err = fmt.Errorf("no capture group")
+		}
+		if err != nil {
+			fmt.Println("Error: invalid --name-pattern:", err)
+			return 1
+		}
+		opts.namePattern = re
+	}
+	if *includeFile != "" {
+		names, err := loadNameList(*includeFile)
+		if err != nil {
+			fmt.Println("Error reading --include-file:", err)
+			return 1
+		}
+		opts.include = names
+	}
+	if *excludeFile != "" {
+		names, err := loadNameList(*excludeFile)
+		if err != nil {
+			fmt.Println("Error reading --exclude-file:", err)
+			return 1
+		}
+		opts.exclude = names
+	}
+
+	if *fieldSep == "" || *kvSep == "" {
+		fmt.Println("Error: --field-sep and --kv-sep must not be empty")
+		return 1
+	}
+	format := parse.Format{FieldSep: *fieldSep, KVSep: *kvSep, TimeLayout: *timeLayout, Lenient: *lenient}
+	opts.parse = format.Parser()
+
+	if *coverage {
+		opts.coverage = newFieldCoverage(format)
+	}
+
+	if opts.byPID && opts.trackByPID {
+		fmt.Println("Error: --by-pid and --track-by-pid-then-name are mutually exclusive")
+		return 1
+	}
+
+	opts.format = formatText
+	switch {
+	case *templateText != "" && *templateFile != "":
+		fmt.Println("Error: --template and --template-file are mutually exclusive")
+		return 1
+	case countTrue(*jsonOut, *csvOut, *promOut, *markdownOut, *compactOut, *templateText != "" || *templateFile != "") > 1:
+		fmt.Println("Error: --json, --csv, --prometheus, --markdown, --compact and --template are mutually exclusive")
+		return 1
+	case *jsonOut:
+		opts.format = formatJSON
+	case *csvOut:
+		opts.format = formatCSV
+	case *promOut:
+		opts.format = formatPrometheus
+	case *markdownOut:
+		opts.format = formatMarkdown
+	case *compactOut:
+		opts.format = formatCompact
+	case *templateText != "" || *templateFile != "":
+		text, err := loadReportTemplate(*templateText, *templateFile)
+		if err != nil {
+			fmt.Println("Error reading --template-file:", err)
+			return 1
+		}
+		// Precision is clamped below; parse errors are reported before any
+		// input is read.
+		if opts.template, err = newReportTemplate(text, clampPrecision(opts.precision), opts.rounding); err != nil {
+			fmt.Println("Error: invalid --template:", err)
+			return 1
+		}
+		opts.format = formatTemplate
+	}
+
+	if !slices.Contains(sortOrders, opts.sortBy) {
+		fmt.Printf("Error: unknown --sort %q (have: %s)\n", opts.sortBy, strings.Join(sortOrders, ", "))
+		return 1
+	}
+	if opts.workers < 1 {
+		fmt.Println("Error: --workers must be at least 1")
+		return 1
+	}
+	var err error
+	if opts.color.enabled, err = useColor(*colorMode); err != nil {
+		fmt.Println("Error:", err)
+		return 1
+	}
+	if !slices.Contains(memUnits, opts.unit) {
+		fmt.Printf("Error: unknown --unit %q (have: %s)\n", opts.unit, strings.Join(memUnits, ", "))
+		return 1
+	}
+	if _, ok := histogramMetrics[opts.histogram]; opts.histogram != "" && !ok {
+		fmt.Printf("Error: unknown --histogram %q (have: cpu, rss, pss)\n", opts.histogram)
+		return 1
+	}
+	if opts.zscore < 0 {
+		fmt.Println("Error: --zscore must not be negative")
+		return 1
+	}
+	if opts.bucketWidth <= 0 {
+		fmt.Println("Error: --bucket-width must be positive")
+		return 1
+	}
+	if *ndjson && !*followFlag {
+		fmt.Println("Error: --ndjson requires --follow")
+		return 1
+	}
+	if *reorderWindow < 0 {
+		fmt.Println("Error: --reorder-window must not be negative")
+		return 1
+	}
+	if *reorderWindow > 0 {
+		opts.reorder = &reorderBuffer{window: *reorderWindow}
+	}
+	if opts.maxLineBytes < 1 {
+		fmt.Println("Error: --max-line-bytes must be positive")
+		return 1
+	}
+	if opts.limitLines < 0 {
+		fmt.Println("Error: --limit-lines must not be negative")
+		return 1
+	}
+	opts.precision = clampPrecision(opts.precision)
+	if !slices.Contains(roundingModes, opts.rounding) {
+		fmt.Printf("Error: unknown --rounding %q (have: %s)\n", opts.rounding, strings.Join(roundingModes, ", "))
+		return 1
+	}
+	if *listenURL != "" && *listenUnix != "" {
+		fmt.Println("Error: --listen cannot be combined with --listen-unix")
+		return 1
+	}
+	if *maxKeys < 0 {
+		fmt.Println("Error: --max-keys must not be negative")
+		return 1
+	}
+	if opts.failOnZombies < 0 {
+		fmt.Println("Error: --fail-on-zombies must not be negative")
+		return 1
+	}
+	if opts.top < 0 {
+		fmt.Println("Error: --top must not be negative")
+		return 1
+	}
+
+	if *pattern != "" {
+		p, err := newRegexParser(*pattern, *timeLayout)
+		if err != nil {
+			fmt.Println("Error: invalid --regex:", err)
+			return 1
+		}
+		opts.parse = p.parse
+	}
+
+	if *lenient && *pattern != "" {
+		fmt.Println("Error: --lenient cannot be combined with --regex")
+		return 1
+	}
+	if opts.validate {
+		if *pattern != "" {
+			fmt.Println("Error: --validate cannot be combined with --regex")
+			return 1
+		}
+		parseValid := opts.parse
+		opts.parse = func(line string) (*parse.LogEntry, error) {
+			if err := format.Validate(line); err != nil {
+				return nil, err
+			}
+			return parseValid(line)
+		}
+	}
+
+	if *exitPriority != "" {
+		priority, err := parseExitPriority(*exitPriority)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return 1
+		}
+		opts.exitPriority = priority
+	}
+
+	if opts.inputFormat != inputLines && opts.inputFormat != inputFramed {
+		fmt.Println("Error: unknown --input-format:", opts.inputFormat)
+		return 1
+	}
+
+	if opts.ewmaAlpha < 0 || opts.ewmaAlpha > 1 {
+		fmt.Println("Error: --ewma-alpha must be between 0 and 1")
+		return 1
+	}
+
+	if *trace && opts.process == "" {
+		fmt.Println("Error: --trace requires --process")
+		return 1
+	}
+	now := time.Now()
+	if opts.since, err = parseTimeBound(*since, now); err != nil {
+		fmt.Println("Error: invalid --since:", err)
+		return 1
+	}
+	if opts.until, err = parseTimeBound(*until, now); err != nil {
+		fmt.Println("Error: invalid --until:", err)
+		return 1
+	}
+	if !opts.since.IsZero() && !opts.until.IsZero() && opts.until.Before(opts.since) {
+		fmt.Println("Error: --until is before --since")
+		return 1
+	}
+
+	if opts.resampleStep > 0 {
+		if *out == "" {
+			fmt.Println("Error: --resample requires --out")
+			return 1
+		}
+		if opts.resampleMaxGap == 0 {
+			opts.resampleMaxGap = 3 * opts.resampleStep
+		}
+	}
+
+	if opts.stabilityBand < 0 {
+		fmt.Println("Error: --stability-band must not be negative")
+		return 1
+	}
+	if opts.baselineMetric != "first" && opts.baselineMetric != "mean" {
+		if _, err := parseSize(opts.baselineMetric); err != nil {
+			fmt.Println("Error: invalid --baseline-metric:", err)
+			return 1
+		}
+	}
+
+	if *outliers {
+		if *outlierFactor <= 0 {
+			fmt.Println("Error: --outlier-factor must be positive")
+			return 1
+		}
+		opts.outlierFactor = *outlierFactor
+	}
+
+	if *eventsFile != "" {
+		events, err := loadEvents(*eventsFile)
+		if err != nil {
+			fmt.Println("Error reading events:", err)
+			return 1
+		}
+		opts.events = events
+	}
+
+	switch opts.percentileMode {
+	case percentileExact:
+	case percentileApprox:
+		// A fixed seed keeps reports reproducible between runs.
+		opts.rng = rand.New(rand.NewPCG(1, 2))
+	default:
+		fmt.Println("Error: unknown --percentile-mode:", opts.percentileMode)
+		return 1
+	}
+	if opts.maxGap > 0 {
+		opts.gaps = true
+	}
+	if opts.gaps && opts.gapFactor <= 1 && opts.maxGap == 0 {
+		fmt.Println("Error: --gap-factor must be greater than 1")
+		return 1
+	}
+	needsDistribution := opts.percentiles || opts.alertP95CPU > 0 || opts.detectLeaks || opts.alertGrowth > 0
+
+	opts.retainSamples = (needsDistribution && opts.percentileMode == percentileExact) ||
+		len(opts.requests) > 0 || opts.stateTimeline || opts.sparkline || opts.histogram != "" || opts.cpuSeconds ||
+		opts.resampleStep > 0 || opts.burstiness || *trace || opts.projectTo > 0 ||
+		opts.stabilityBand > 0 || opts.gaps || opts.cpuThreshold > 0
+
+	opts.out = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Println("Error creating output file:", err)
+			return 1
+		}
+		defer file.Close() //nolint:errcheck
+		opts.out = file
+	}
+	if name == cmdValidate {
+		// Only the verdict matters; invalid lines are listed on stderr.
+		opts.out = io.Discard
+	}
+
+	if *maxKeys > 0 {
+		if *watchInterval > 0 || name == cmdDiff {
+			fmt.Println("Error: --max-keys cannot be combined with --watch or diff")
+			return 1
+		}
+		if opts.format != formatText && opts.format != formatTemplate {
+			fmt.Println("Error: --max-keys only supports the text report and --template")
+			return 1
+		}
+		opts.keys = newKeyLimiter(*maxKeys)
+	}
+
+	if *sqlitePath != "" {
+		if *watchInterval > 0 || name == cmdDiff {
+			fmt.Println("Error: --sqlite cannot be combined with --watch or diff")
+			return 1
+		}
+		export, err := openSQLite(*sqlitePath)
+		if err != nil {
+			fmt.Println("Error opening SQLite database:", err)
+			return 1
+		}
+		defer export.db.Close() //nolint:errcheck
+		opts.sqlite = export
+	}
+
+	if *errorsOut != "" {
+		file, err := os.Create(*errorsOut)
+		if err != nil {
+			fmt.Println("Error creating errors file:", err)
+			return 1
 		}
 		defer file.Close() //nolint:errcheck
-		reader = file
+		errLog := bufio.NewWriter(file)
+		defer errLog.Flush() //nolint:errcheck
+		opts.errLog = errLog
+	}
+
+	if *listenURL != "" {
+		stats, err := serveURL(*listenURL, opts, *keepListening)
+		if err != nil {
+			fmt.Println("Error listening:", err)
+			return 1
+		}
+		if !closeSQLite(opts) {
+			return 1
+		}
+		return report(stats, opts)
+	}
+
+	if *listenUnix != "" {
+		stats, err := serveUnix(*listenUnix, opts)
+		if err != nil {
+			fmt.Println("Error listening on socket:", err)
+			return 1
+		}
+		if !closeSQLite(opts) {
+			return 1
+		}
+		return report(stats, opts)
+	}
+
+	if name == cmdDiff {
+		return runDiff(diffArgs, opts)
+	}
+
+	if *watchInterval > 0 {
+		if fs.NArg() == 0 || *followFlag {
+			fmt.Println("Error: --watch requires log files and cannot be combined with --follow")
+			return 1
+		}
+		paths, err := expandPaths(fs.Args(), *recursive)
+		if err != nil {
+			fmt.Println("Error reading directory:", err)
+			return 1
+		}
+		return watch(paths, *watchInterval, opts, *strict)
+	}
+
+	if !*quiet && isTerminal(os.Stderr) {
+		opts.progress = os.Stderr
+	}
+
+	var live *liveStats
+	if *serveAddr != "" {
+		live = newLiveStats()
+		srv, err := startHTTP(*serveAddr, live, opts.precision, opts.rounding)
+		if err != nil {
+			fmt.Println("Error starting HTTP server:", err)
+			return 1
+		}
+		defer stopHTTP(srv)
+	}
+
+	var stats map[string]parse.ProcessStats
+
+	if *followFlag {
+		if fs.NArg() != 1 {
+			fmt.Println("Error: --follow requires exactly one log file")
+			return 1
+		}
+		if opts.reorder != nil {
+			fmt.Println("Error: --reorder-window cannot be combined with --follow")
+			return 1
+		}
+		if *ndjson {
+			opts.ndjson = opts.out
+		}
+		if live == nil {
+			live = newLiveStats()
+		}
+		var err error
+		stats, err = follow(fs.Arg(0), *followInterval, opts, live)
+		if err != nil {
+			fmt.Println("Error following log:", err)
+			return 1
+		}
+		if opts.ndjson != nil {
+			// The stream already holds every sample.
+			return 0
+		}
+	} else if fs.NArg() > 0 {
+		// If file paths are provided as arguments, use them.
+		var err error
+		paths, err := expandPaths(fs.Args(), *recursive)
+		if err != nil {
+			fmt.Println("Error reading directory:", err)
+			return 1
+		}
+		stats, err = processFiles(paths, opts, *strict)
+		if err != nil {
+			fmt.Println("Error processing logs:", err)
+			return 1
+		}
 	} else {
 		// Otherwise, check if there is piped input.
 		stat, err := os.Stdin.Stat()
 		if err != nil {
 			fmt.Println("Error reading stdin:", err)
-			return
+			return 1
 		}
 		if (stat.Mode() & os.ModeCharDevice) != 0 {
-			fmt.Println("Usage: <log_file_path> or pipe log data to stdin")
-			return
+			if name == cmdServe {
+				fmt.Println("Usage: serve [flags] <log_file_path>... or pipe log data to stdin")
+			} else {
+				fmt.Println("Usage: <log_file_path>..., pipe log data to stdin, or one of --listen=tcp://host:port|unix:///path and --listen-unix=<socket_path>")
+			}
+			return 1
+		}
+		stats = make(map[string]parse.ProcessStats)
+		var in io.Reader = os.Stdin
+		var progress *progressReader
+		if opts.progress != nil {
+			progress = newProgressReader(os.Stdin, opts.progress, "stdin", 0)
+			in = progress
+		}
+		err = aggregateReader(stats, in, opts)
+		if progress != nil {
+			progress.done()
 		}
-		reader = os.Stdin
+		if err != nil {
+			fmt.Println("Error processing logs:", err)
+			return 1
+		}
+		finalizeStats(stats)
+	}
+
+	if !closeSQLite(opts) {
+		return 1
+	}
+
+	// Without --follow the stats are complete; keep serving them until
+	// interrupted.
+	if live != nil && !*followFlag {
+		_ = live.update(func(m map[string]parse.ProcessStats) error {
+			maps.Copy(m, stats)
+			return nil
+		})
+		fmt.Fprintln(os.Stderr, "Serving stats on", *serveAddr+"; interrupt to exit")
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		<-ctx.Done()
+		stop()
 	}
 
-	stats, err := processLogs(reader)
+	if *trace {
+		// --process already dropped every other process, but its samples
+		// may be spread across keys such as name#pid or host/name.
+		keys := sortedNames(stats, sortName)
+		for i, key := range keys {
+			if len(keys) > 1 {
+				if i > 0 {
+					_, _ = fmt.Fprintln(opts.out)
+				}
+				_, _ = fmt.Fprintf(opts.out, "%s:\n", key)
+			}
+			printTrace(opts.out, stats[key].Samples, opts.precision, opts.rounding)
+		}
+		return 0
+	}
+
+	if *get != "" {
+		v, err := selectMetric(stats, *get)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		_, _ = fmt.Fprintln(opts.out, strconv.FormatFloat(roundTo(v, opts.precision, opts.rounding), 'f', -1, 64))
+		return 0
+	}
+
+	if opts.resampleStep > 0 {
+		if err := writeSeriesFile(*out, stats, opts); err != nil {
+			fmt.Println("Error writing resampled series:", err)
+			return 1
+		}
+	}
+
+	return report(stats, opts)
+}
+
+// normalizeName returns the text captured by the first group of pattern in
+// name, or name itself when it does not match or the group is empty.
+func normalizeName(pattern *regexp.Regexp, name string) string {
+	if m := pattern.FindStringSubmatch(name); m != nil && m[1] != "" {
+		return m[1]
+	}
+	return name
+}
+
+// compileFilter compiles a --filter pattern. Patterns match anywhere in the
+// name and ignore case, unless anchored with both ^ and $, which makes them a
+// case-sensitive match of the whole name.
+func compileFilter(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil || (strings.HasPrefix(pattern, "^") && strings.HasSuffix(pattern, "$")) {
+		return re, err
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// parseTimeBound parses an optional RFC3339 time or a duration relative to
+// now such as -30m; "" yields the zero time.
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if strings.HasPrefix(value, "-") {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// writeSeriesFile writes the resampled series of stats to path.
+func writeSeriesFile(path string, stats map[string]parse.ProcessStats, opts options) error {
+	file, err := os.Create(path)
 	if err != nil {
-		fmt.Println("Error processing logs:", err)
-		return
+		return err
+	}
+	if err := writeResampled(file, stats, opts.resampleStep, opts.resampleMaxGap, opts.precision, opts.rounding); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeAlertsFile writes alerts as NDJSON to path, or to stderr for "-".
+func writeAlertsFile(path string, alerts []alert) error {
+	if path == "-" {
+		return writeAlertsNDJSON(os.Stderr, alerts)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeAlertsNDJSON(file, alerts); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// report prints the aggregated stats along with any warnings about the data,
+// then evaluates the configured alerts. It returns the process exit code.
+func report(stats map[string]parse.ProcessStats, opts options) int {
+	if opts.skips != nil && opts.skips.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: skipped %s\n", plural(opts.skips.Skipped, "malformed line"))
+		for _, msg := range opts.skips.First {
+			fmt.Fprintln(os.Stderr, "  "+msg)
+		}
+	}
+	if opts.reorder != nil && opts.reorder.OutOfOrder > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: reordered %s", plural(opts.reorder.OutOfOrder, "out-of-order line"))
+		if opts.reorder.TooLate > 0 {
+			fmt.Fprintf(os.Stderr, "; %d arrived more than --reorder-window late and were aggregated out of order", opts.reorder.TooLate)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+	if opts.skips != nil && opts.skips.Sanitized > 0 && (opts.format != formatText || opts.noSummary) {
+		fmt.Fprintf(os.Stderr, "Warning: clamped %s to 0 (use --no-negative to skip such lines)\n", plural(opts.skips.Sanitized, "negative value"))
+	}
+	if opts.keys != nil && opts.keys.Evicted > 0 {
+		fmt.Fprintf(os.Stderr, "Note: --max-keys evicted %s; evicted processes were reported when dropped and are left out of the summary and alerts\n",
+			plural(opts.keys.Evicted, "key"))
+	}
+	if !opts.cpuFraction && looksFractional(stats) {
+		fmt.Fprintln(os.Stderr, "Warning: no CPU value exceeds 1.0; the log may report CPU as a fraction (try --cpu-fraction)")
+	}
+	// --top trims the per-process report; alerts and the host-wide
+	// sections still see every process.
+	shown := topStats(stats, opts.sortBy, opts.top)
+	switch opts.format {
+	case formatJSON:
+		if err := printStatsJSON(opts.out, shown, opts.precision, opts.rounding); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing JSON:", err)
+			return 1
+		}
+	case formatCSV:
+		if err := printStatsCSV(opts.out, shown, opts.precision, opts.rounding); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing CSV:", err)
+			return 1
+		}
+	case formatMarkdown:
+		if err := printStatsMarkdown(opts.out, shown, opts.precision, opts.rounding); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing Markdown:", err)
+			return 1
+		}
+	case formatCompact:
+		if err := printStatsCompact(opts.out, shown, opts.sortBy, opts.precision, opts.rounding); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing report:", err)
+			return 1
+		}
+	case formatTemplate:
+		if err := printStatsTemplate(opts.out, shown, opts.sortBy, opts.template); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing report:", err)
+			return 1
+		}
+	case formatPrometheus:
+		if err := printStatsPrometheus(opts.out, shown); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing Prometheus metrics:", err)
+			return 1
+		}
+	default:
+		// The summary totals every process, not just the --top rows.
+		rowOpts := opts
+		rowOpts.noSummary = true
+		printStats(opts.out, shown, rowOpts)
+		if !opts.noSummary {
+			printReportSummary(opts.out, stats, opts)
+		}
+		if opts.totalMemory > 0 {
+			printMemoryPressure(opts.out, stats, opts)
+		}
+		if opts.outlierFactor > 0 {
+			printOutliers(opts.out, stats, opts.outlierFactor)
+		}
+		if opts.byState {
+			printStateSummary(opts.out, stats)
+		}
+		if opts.coverage != nil {
+			opts.coverage.print(opts.out)
+		}
 	}
 
-	printStats(stats)
+	alerts := evaluateAlerts(stats, opts)
+	for _, a := range alerts {
+		fmt.Fprintln(os.Stderr, "Alert:", a)
+	}
+	if opts.alertsNDJSON != "" {
+		if err := writeAlertsFile(opts.alertsNDJSON, alerts); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing alerts:", err)
+			return 1
+		}
+	}
+	code := exitCode(alerts, opts.exitCodes, opts.exitPriority)
+	if code == 0 && opts.validate && opts.skips != nil && opts.skips.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s failed --validate\n", plural(opts.skips.Skipped, "line"))
+		return 1
+	}
+	return code
 }
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// logLine formats a log line in the layout sauron writes.
+func logLine(pid int, name, state string, rss, cpu float64, checked string) string {
+	return fmt.Sprintf("PID: %d | Name: %s | State: %s | Threads: 2 | RSS (MB): %g | VSZ (MB): 20.0 | PSS (MB): 5.0 | CPU (%%): %g | Uptime (sec): 100.0 | Last Checked: %s",
+		pid, name, state, rss, cpu, checked)
+}
+
+// testOptions returns the options of a run without flags.
+func testOptions() options {
+	return options{
+		maxLineBytes: bufio.MaxScanTokenSize,
+		maxErrors:    -1,
+		out:          io.Discard,
+		format:       formatText,
+		unit:         unitMB,
+		precision:    defaultPrecision,
+		rounding:     roundHalfEven,
+		sortBy:       sortName,
+	}
+}
+
+// aggregate runs processLogs over lines and fails the test on error.
+func aggregate(t *testing.T, opts options, lines ...string) map[string]parse.ProcessStats {
+	t.Helper()
+	stats, _, err := processLogs(strings.NewReader(strings.Join(lines, "\n")+"\n"), opts)
+	if err != nil {
+		t.Fatalf("processLogs: %v", err)
+	}
+	return stats
+}
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"http", "HTTPD", true},
+		{"http", "mdnsd", false},
+		{"^httpd$", "httpd", true},
+		{"^httpd$", "HTTPD", false},
+		{"^httpd$", "httpd-worker", false},
+	}
+	for _, tt := range tests {
+		re, err := compileFilter(tt.pattern)
+		if err != nil {
+			t.Fatalf("compileFilter(%q): %v", tt.pattern, err)
+		}
+		if got := re.MatchString(tt.name); got != tt.want {
+			t.Errorf("compileFilter(%q) matches %q = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+	if _, err := compileFilter("http(d"); err == nil {
+		t.Error("compileFilter accepted an unbalanced pattern")
+	}
+}
+
+func TestFilterAggregatesMatchesOnly(t *testing.T) {
+	opts := testOptions()
+	opts.filter, _ = compileFilter("http")
+	stats := aggregate(t, opts,
+		logLine(1, "httpd", "Running", 10, 1, "2025-02-21T12:41:52Z"),
+		logLine(2, "mdnsd", "Running", 10, 1, "2025-02-21T12:41:52Z"),
+	)
+	if _, ok := stats["httpd"]; !ok || len(stats) != 1 {
+		t.Errorf("stats = %v, want only httpd", sortedNames(stats, sortName))
+	}
+}
+
+// reportValue returns the value printed after label in a text report, or ""
+// if no line has that label.
+func reportValue(report, label string) string {
+	for _, line := range strings.Split(report, "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), label); ok {
+			return strings.TrimSpace(rest)
+		}
+	}
+	return ""
+}
+
+// textReport prints stats as text and returns the output.
+func textReport(t *testing.T, stats map[string]parse.ProcessStats, opts options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	printStats(&buf, stats, opts)
+	return buf.String()
+}
+
+func TestObservationWindow(t *testing.T) {
+	stats := aggregate(t, testOptions(),
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:30:00Z"),
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T13:30:00Z"),
+	)
+	if got := stats["foo"].LatestTime.Sub(stats["foo"].FirstTime); got != 90*time.Minute {
+		t.Errorf("window = %v, want 1h30m", got)
+	}
+	out := textReport(t, stats, testOptions())
+	if got := reportValue(out, "Observation Window:"); got != "1h30m0s (3 samples)" {
+		t.Errorf("Observation Window = %q", got)
+	}
+	if got := reportValue(out, "Avg Sample Interval:"); got != "45m0s" {
+		t.Errorf("Avg Sample Interval = %q", got)
+	}
+
+	single := textReport(t, aggregate(t, testOptions(), logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z")), testOptions())
+	if got := reportValue(single, "Observation Window:"); got != "0s (1 sample)" {
+		t.Errorf("single sample Observation Window = %q", got)
+	}
+	if strings.Contains(single, "Avg Sample Interval:") {
+		t.Error("single sample report has a sample interval")
+	}
+}
+
+func TestProcessLogsSkipsMalformedLines(t *testing.T) {
+	log := strings.Join([]string{
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		"garbage",
+		"PID: 1 | Name: foo | State: Running | RSS (MB): lots",
+		logLine(1, "foo", "Running", 20, 1, "2025-02-21T12:01:00Z"),
+		"Name: foo | State: Running | Last Checked: yesterday",
+	}, "\n")
+
+	stats, skips, err := processLogs(strings.NewReader(log), testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skips.Skipped != 3 || len(skips.First) != 3 || !strings.HasPrefix(skips.First[0], "line 2: ") {
+		t.Errorf("skips = %d %q, want 3 starting at line 2", skips.Skipped, skips.First)
+	}
+	if foo := stats["foo"]; foo.Count != 2 || foo.MaxMemory != 20 {
+		t.Errorf("foo count %d, max RSS %v; want 2 and 20", foo.Count, foo.MaxMemory)
+	}
+
+	opts := testOptions()
+	opts.maxErrors = 2
+	if _, _, err := processLogs(strings.NewReader(log), opts); !errors.Is(err, errTooManyErrors) {
+		t.Errorf("--max-errors 2: err = %v, want errTooManyErrors", err)
+	}
+}
+
+func TestByPIDKeysInstancesApart(t *testing.T) {
+	lines := []string{
+		logLine(100, "nginx", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		logLine(200, "nginx", "Running", 90, 1, "2025-02-21T12:00:00Z"),
+		logLine(100, "nginx", "Running", 12, 1, "2025-02-21T12:01:00Z"),
+	}
+	if stats := aggregate(t, testOptions(), lines...); len(stats) != 1 || stats["nginx"].Count != 3 {
+		t.Errorf("by name: keys %v, want nginx with 3 samples", sortedNames(stats, sortName))
+	}
+
+	opts := testOptions()
+	opts.byPID = true
+	stats := aggregate(t, opts, lines...)
+	if got := sortedNames(stats, sortName); len(got) != 2 {
+		t.Fatalf("--by-pid keys = %v, want nginx#100 and nginx#200", got)
+	}
+	if a, b := stats["nginx#100"], stats["nginx#200"]; a.Count != 2 || a.MaxMemory != 12 || b.Count != 1 || b.MaxMemory != 90 {
+		t.Errorf("nginx#100 %d samples max %v, nginx#200 %d samples max %v", a.Count, a.MaxMemory, b.Count, b.MaxMemory)
+	}
+}
+
+func TestSinceUntil(t *testing.T) {
+	opts := testOptions()
+	opts.since = time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	opts.until = time.Date(2025, 2, 21, 13, 0, 0, 0, time.UTC)
+	stats := aggregate(t, opts,
+		logLine(1, "before", "Running", 1, 1, "2025-02-21T11:59:59Z"),
+		logLine(1, "since", "Running", 1, 1, "2025-02-21T12:00:00Z"),
+		logLine(1, "inside", "Running", 1, 1, "2025-02-21T12:30:00Z"),
+		logLine(1, "until", "Running", 1, 1, "2025-02-21T15:00:00+02:00"),
+		logLine(1, "after", "Running", 1, 1, "2025-02-21T13:00:01Z"),
+	)
+	if got := sortedNames(stats, sortName); !slices.Equal(got, []string{"inside", "since", "until"}) {
+		t.Errorf("in range: %v, want inside and both boundaries", got)
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"", time.Time{}},
+		{"-30m", now.Add(-30 * time.Minute)},
+		{"2025-02-21T10:00:00Z", time.Date(2025, 2, 21, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got, err := parseTimeBound(tt.value, now); err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseTimeBound(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"yesterday", "-lots", "2025-02-21"} {
+		if _, err := parseTimeBound(bad, now); err == nil {
+			t.Errorf("parseTimeBound(%q) accepted a bad value", bad)
+		}
+	}
+}
+
+func TestReportWritesToOutput(t *testing.T) {
+	stats := aggregate(t, testOptions(), logLine(1, "foo", "Running", 10.5, 2, "2025-02-21T12:00:00Z"))
+	for _, tt := range []struct {
+		format string
+		want   string
+	}{
+		{formatText, "Process foo:"},
+		{formatJSON, `"foo": {`},
+		{formatCSV, "foo,Running,1,2.00,2.00,2.00,2.00,10.50,"},
+		{formatMarkdown, "| foo | Running | 2.00 |"},
+	} {
+		var buf bytes.Buffer
+		opts := testOptions()
+		opts.out = &buf
+		opts.format = tt.format
+		if code := report(stats, opts); code != 0 {
+			t.Errorf("%s: exit code %d", tt.format, code)
+		}
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("%s output does not contain %q:\n%s", tt.format, tt.want, buf.String())
+		}
+	}
+}
+
+func TestProcessLogsCRLF(t *testing.T) {
+	log := logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z") + "\r\n" +
+		"  PID: 1 |  Name:  foo  | State: Running |  RSS (MB):   20  | VSZ (MB): 20 | PSS (MB): 5 | CPU (%): 3 | Last Checked:  2025-02-21T12:01:00Z \t\r\n"
+	stats, skips, err := processLogs(strings.NewReader(log), testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skips.Skipped != 0 {
+		t.Fatalf("skipped %d CRLF lines: %q", skips.Skipped, skips.First)
+	}
+	foo := stats["foo"]
+	if want := time.Date(2025, 2, 21, 12, 1, 0, 0, time.UTC); foo.Count != 2 || foo.LatestMemory != 20 || !foo.LatestTime.Equal(want) {
+		t.Errorf("foo = count %d, latest RSS %v at %v", foo.Count, foo.LatestMemory, foo.LatestTime)
+	}
+}
+
+func TestFormatRatio(t *testing.T) {
+	tests := []struct {
+		rss, pss float64
+		want     string
+	}{
+		{100, 25, "4.00"},
+		{10, 10, "1.00"},
+		{100, 0, "n/a"},
+		{0, 0, "n/a"},
+	}
+	for _, tt := range tests {
+		if got := formatRatio(tt.rss, tt.pss, 2, roundHalfEven); got != tt.want {
+			t.Errorf("formatRatio(%v, %v) = %q, want %q", tt.rss, tt.pss, got, tt.want)
+		}
+	}
+}
+
+func TestNamePatternCollapsesInstances(t *testing.T) {
+	opts := testOptions()
+	opts.namePattern = regexp.MustCompile(`^(worker)-\d+$`)
+	stats := aggregate(t, opts,
+		logLine(1, "worker-1234", "Running", 10, 1, "2025-02-21T12:41:52Z"),
+		logLine(2, "worker-5678", "Running", 20, 3, "2025-02-21T12:41:52Z"),
+		logLine(3, "httpd", "Running", 10, 1, "2025-02-21T12:41:52Z"),
+	)
+	if names := sortedNames(stats, sortName); len(names) != 2 || names[0] != "httpd" || names[1] != "worker" {
+		t.Fatalf("stats = %v, want [httpd worker]", names)
+	}
+	if n := stats["worker"].Count; n != 2 {
+		t.Errorf("worker count = %d, want 2", n)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	pattern := regexp.MustCompile(`^(worker)?-\d+$`)
+	for name, want := range map[string]string{
+		"worker-1234": "worker",
+		"-1234":       "-1234", // empty group keeps the raw name
+		"httpd":       "httpd",
+	} {
+		if got := normalizeName(pattern, name); got != want {
+			t.Errorf("normalizeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPrintStatsUptime(t *testing.T) {
+	var lines []string
+	for i, up := range []string{"3000.0", "3300.0", "60.0"} {
+		line := logLine(1, "foo", "Running", 10, 1, fmt.Sprintf("2025-02-21T12:0%d:00Z", i*4))
+		lines = append(lines, strings.Replace(line, "Uptime (sec): 100.0", "Uptime (sec): "+up, 1))
+	}
+	opts := testOptions()
+	opts.minUptime = 5 * time.Minute
+	out := textReport(t, aggregate(t, opts, lines...), opts)
+	if got, want := reportValue(out, "Uptime:"), "1m0s (1 restart, below --min-uptime)"; got != want {
+		t.Errorf("Uptime = %q, want %q", got, want)
+	}
+	if got, want := reportValue(out, "Uptime Range:"), "1m0s – 55m0s"; got != want {
+		t.Errorf("Uptime Range = %q, want %q", got, want)
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	for seconds, want := range map[float64]string{0: "0s", 59.6: "1m0s", 7500: "2h5m0s"} {
+		if got := formatUptime(seconds); got != want {
+			t.Errorf("formatUptime(%v) = %q, want %q", seconds, got, want)
+		}
+	}
+}
+
+func TestGroupByHost(t *testing.T) {
+	onHost := func(host string, rss float64) string {
+		return "Host: " + host + " | " + logLine(1, "httpd", "Running", rss, 1, "2025-02-21T12:41:52Z")
+	}
+	opts := testOptions()
+	stats := aggregate(t, opts, onHost("cam-b", 30), onHost("cam-a", 10), onHost("cam-a", 20))
+	if names := sortedNames(stats, sortName); !slices.Equal(names, []string{"cam-a/httpd", "cam-b/httpd"}) {
+		t.Fatalf("stats = %v, want cam-a/httpd and cam-b/httpd", names)
+	}
+	if a, b := stats["cam-a/httpd"], stats["cam-b/httpd"]; a.Count != 2 || a.MaxMemory != 20 || a.Host != "cam-a" || b.Count != 1 || b.Host != "cam-b" {
+		t.Errorf("cam-a %d samples max %v on %q, cam-b %d samples on %q", a.Count, a.MaxMemory, a.Host, b.Count, b.Host)
+	}
+
+	var headers []string
+	for _, line := range strings.Split(textReport(t, stats, opts), "\n") {
+		if strings.HasPrefix(line, "Host ") || strings.HasPrefix(line, "Process ") {
+			headers = append(headers, line)
+		}
+	}
+	want := []string{"Host cam-a:", "Process httpd:", "Host cam-b:", "Process httpd:"}
+	if !slices.Equal(headers, want) {
+		t.Errorf("report headers = %q, want %q", headers, want)
+	}
+
+	// Without host information the keys stay plain names.
+	stats = aggregate(t, opts, logLine(1, "httpd", "Running", 10, 1, "2025-02-21T12:41:52Z"))
+	if _, ok := stats["httpd"]; !ok || hasHosts(stats) {
+		t.Errorf("stats without hosts = %v", sortedNames(stats, sortName))
+	}
+}
+
+func TestNegativeMetrics(t *testing.T) {
+	log := strings.Join([]string{
+		logLine(1, "foo", "Running", 10, 2, "2025-02-21T12:00:00Z"),
+		logLine(1, "foo", "Running", -4, -1, "2025-02-21T12:01:00Z"),
+	}, "\n")
+
+	stats, skips, err := processLogs(strings.NewReader(log), testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if foo := stats["foo"]; foo.Count != 2 || foo.MinMemory != 0 || foo.MinCPU != 0 || skips.Sanitized != 2 {
+		t.Errorf("clamped: count %d, min RSS %v, min CPU %v, sanitized %d; want 2, 0, 0 and 2",
+			foo.Count, foo.MinMemory, foo.MinCPU, skips.Sanitized)
+	}
+
+	opts := testOptions()
+	opts.noNegative = true
+	stats, skips, err = processLogs(strings.NewReader(log), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if foo := stats["foo"]; foo.Count != 1 || foo.MinMemory != 10 {
+		t.Errorf("--no-negative: count %d, min RSS %v; want 1 and 10", foo.Count, foo.MinMemory)
+	}
+	if skips.Skipped != 1 || !strings.HasSuffix(skips.First[0], "line 2: negative CPU value") {
+		t.Errorf("--no-negative skips = %d %q, want line 2 negative CPU", skips.Skipped, skips.First)
+	}
+}
+
+func TestCostPerRequest(t *testing.T) {
+	opts := testOptions()
+	opts.byPID = true
+	opts.retainSamples = true
+	opts.gapFactor = 5
+	opts.requests = requestCounts{"httpd": 100}
+	start := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	var lines []string
+	// 50% CPU for a minute, then a sample an hour later that is not integrated.
+	for i := range 7 {
+		lines = append(lines, logLine(1, "httpd", "Running", 10, 50, start.Add(time.Duration(i)*10*time.Second).Format(time.RFC3339)))
+	}
+	lines = append(lines, logLine(1, "httpd", "Running", 10, 50, start.Add(time.Hour).Format(time.RFC3339)))
+
+	stats := aggregate(t, opts, lines...)
+	if _, ok := stats["httpd#1"]; !ok {
+		t.Fatalf("stats = %v, want httpd#1", sortedNames(stats, sortName))
+	}
+	if got, want := reportValue(textReport(t, stats, opts), "Cost per request:"), "~300.00ms CPU/request, ~102.4KB RSS/request"; got != want {
+		t.Errorf("Cost per request = %q, want %q", got, want)
+	}
+}
+
+func TestPercentileLinesUseUnit(t *testing.T) {
+	opts := testOptions()
+	opts.retainSamples = true
+	opts.percentiles = true
+	opts.unit = unitGB
+	opts.precision = 1
+	stats := aggregate(t, opts,
+		logLine(1, "foo", "Running", 1024, 1, "2025-02-21T12:00:00Z"),
+		logLine(1, "foo", "Running", 2048, 3, "2025-02-21T12:01:00Z"),
+	)
+	out := textReport(t, stats, opts)
+	if got, want := reportValue(out, "RSS p50/p95/p99:"), "1.5 GB / 2.0 GB / 2.0 GB"; got != want {
+		t.Errorf("RSS percentiles = %q, want %q", got, want)
+	}
+	if got, want := reportValue(out, "CPU p50/p95/p99:"), "2.0% / 2.9% / 3.0%"; got != want {
+		t.Errorf("CPU percentiles = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// metricNames lists the aggregate metrics addressable by name, e.g. in
+// --get selectors.
+var metricNames = []string{
+	"count",
+	"avg_cpu", "min_cpu", "max_cpu", "latest_cpu",
+	"avg_rss", "min_rss", "max_rss", "latest_rss",
+	"avg_pss", "min_pss", "max_pss", "latest_pss",
+	"avg_vsz", "min_vsz", "max_vsz", "latest_vsz",
+}
+
+// metricValue returns the named aggregate metric of stat.
+func metricValue(stat parse.ProcessStats, metric string) (float64, bool) {
+	n := float64(stat.Count)
+	switch metric {
+	case "count":
+		return n, true
+	case "avg_cpu":
+		return stat.TotalCPU / n, true
+	case "min_cpu":
+		return stat.MinCPU, true
+	case "max_cpu":
+		return stat.MaxCPU, true
+	case "latest_cpu":
+		return stat.LatestCPU, true
+	case "avg_rss":
+		return stat.TotalMemory / n, true
+	case "min_rss":
+		return stat.MinMemory, true
+	case "max_rss":
+		return stat.MaxMemory, true
+	case "latest_rss":
+		return stat.LatestMemory, true
+	case "avg_pss":
+		return stat.TotalPSS / n, true
+	case "min_pss":
+		return stat.MinPSS, true
+	case "max_pss":
+		return stat.MaxPSS, true
+	case "latest_pss":
+		return stat.LatestPSS, true
+	case "avg_vsz":
+		return stat.TotalVSZ / n, true
+	case "min_vsz":
+		return stat.MinVSZ, true
+	case "max_vsz":
+		return stat.MaxVSZ, true
+	case "latest_vsz":
+		return stat.LatestVSZ, true
+	}
+	return 0, false
+}
+
+// selectMetric resolves a process.metric selector against stats. The
+// selector is split at its last dot so process names may contain dots.
+func selectMetric(stats map[string]parse.ProcessStats, selector string) (float64, error) {
+	i := strings.LastIndex(selector, ".")
+	if i <= 0 || i == len(selector)-1 {
+		return 0, fmt.Errorf("invalid selector %q, expected process.metric", selector)
+	}
+	name, metric := selector[:i], selector[i+1:]
+
+	stat, ok := stats[name]
+	if !ok {
+		names := make([]string, 0, len(stats))
+		for n := range stats {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return 0, fmt.Errorf("unknown process %q (have: %s)", name, strings.Join(names, ", "))
+	}
+	v, ok := metricValue(stat, metric)
+	if !ok {
+		return 0, fmt.Errorf("unknown metric %q (have: %s)", metric, strings.Join(metricNames, ", "))
+	}
+	return v, nil
+}
+
+// peakRatio returns peak divided by avg, or 0 when avg is not positive.
+func peakRatio(peak, avg float64) float64 {
+	if avg <= 0 {
+		return 0
+	}
+	return peak / avg
+}
+
+// Supported --sort orders.
+const (
+	sortName      = "name"
+	sortCPU       = "cpu"
+	sortRSS       = "rss"
+	sortPSS       = "pss"
+	sortPeakRatio = "peak-ratio"
+)
+
+// sortOrders lists the valid --sort values.
+var sortOrders = []string{sortName, sortCPU, sortRSS, sortPSS, sortPeakRatio}
+
+// sortKey returns the numeric value stat is ordered by for a --sort order.
+func sortKey(stat parse.ProcessStats, by string) float64 {
+	switch by {
+	case sortCPU:
+		return stat.LatestCPU
+	case sortRSS:
+		return stat.LatestMemory
+	case sortPSS:
+		return stat.LatestPSS
+	case sortPeakRatio:
+		return peakRatio(stat.MaxMemory, stat.TotalMemory/float64(stat.Count))
+	}
+	return 0
+}
+
+// processLess reports whether process a sorts before b. Numeric orders are
+// descending by the latest value (or ratio), with ties broken by name;
+// sortName is ascending by name.
+func processLess(stats map[string]parse.ProcessStats, by, a, b string) bool {
+	if by != sortName {
+		if ka, kb := sortKey(stats[a], by), sortKey(stats[b], by); ka != kb {
+			return ka > kb
+		}
+	}
+	return a < b
+}
+
+// sortedNames returns the process names of stats in the given order.
+func sortedNames(stats map[string]parse.ProcessStats, by string) []string {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return processLess(stats, by, names[i], names[j]) })
+	return names
+}
+
+// topStats returns the n processes of stats that sort first in the given
+// order, or stats itself when n is zero or covers every process. Selection
+// keeps a bounded heap, so only the survivors are ever fully ordered.
+func topStats(stats map[string]parse.ProcessStats, by string, n int) map[string]parse.ProcessStats {
+	if n <= 0 || n >= len(stats) {
+		return stats
+	}
+	h := &nameHeap{stats: stats, by: by}
+	for name := range stats {
+		if h.Len() < n {
+			heap.Push(h, name)
+		} else if processLess(stats, by, name, h.names[0]) {
+			h.names[0] = name
+			heap.Fix(h, 0)
+		}
+	}
+	top := make(map[string]parse.ProcessStats, n)
+	for _, name := range h.names {
+		top[name] = stats[name]
+	}
+	return top
+}
+
+// nameHeap is a heap of process names whose root is the one sorting last,
+// i.e. the first to be evicted by a better candidate.
+type nameHeap struct {
+	names []string
+	stats map[string]parse.ProcessStats
+	by    string
+}
+
+func (h *nameHeap) Len() int           { return len(h.names) }
+func (h *nameHeap) Less(i, j int) bool { return processLess(h.stats, h.by, h.names[j], h.names[i]) }
+func (h *nameHeap) Swap(i, j int)      { h.names[i], h.names[j] = h.names[j], h.names[i] }
+func (h *nameHeap) Push(x any)         { h.names = append(h.names, x.(string)) }
+func (h *nameHeap) Pop() any {
+	last := h.names[len(h.names)-1]
+	h.names = h.names[:len(h.names)-1]
+	return last
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// sortFixture has a different leader for every latest metric, plus a CPU tie
+// broken by name.
+var sortFixture = map[string]parse.ProcessStats{
+	"bravo":   {LatestCPU: 5, LatestMemory: 30, LatestPSS: 1, Count: 1},
+	"alpha":   {LatestCPU: 5, LatestMemory: 10, LatestPSS: 3, Count: 1},
+	"charlie": {LatestCPU: 9, LatestMemory: 20, LatestPSS: 2, Count: 1},
+}
+
+func TestSortedNames(t *testing.T) {
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{sortName, []string{"alpha", "bravo", "charlie"}},
+		{sortCPU, []string{"charlie", "alpha", "bravo"}},
+		{sortRSS, []string{"bravo", "charlie", "alpha"}},
+		{sortPSS, []string{"alpha", "charlie", "bravo"}},
+	}
+	for _, tt := range tests {
+		if got := sortedNames(sortFixture, tt.by); !slices.Equal(got, tt.want) {
+			t.Errorf("sortedNames(%s) = %v, want %v", tt.by, got, tt.want)
+		}
+	}
+}
+
+func TestTopStats(t *testing.T) {
+	stats := map[string]parse.ProcessStats{
+		"small":  {LatestMemory: 5, Count: 1},
+		"large":  {LatestMemory: 50, Count: 1},
+		"medium": {LatestMemory: 20, Count: 1},
+		"tie-b":  {LatestMemory: 20, Count: 1},
+	}
+	top := topStats(stats, sortRSS, 2)
+	if got := sortedNames(top, sortRSS); !slices.Equal(got, []string{"large", "medium"}) {
+		t.Errorf("--top 2 --sort rss = %v, want [large medium]", got)
+	}
+	if got := topStats(stats, sortRSS, 10); len(got) != len(stats) {
+		t.Errorf("--top 10 kept %d of %d processes", len(got), len(stats))
+	}
+}
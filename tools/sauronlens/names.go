@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// nameList is a list of process names or glob patterns, as read from an
+// --include-file or --exclude-file.
+type nameList []string
+
+// loadNameList reads the names in the file at filename, one per line.
+// Blank lines and lines starting with # are ignored. Each name may be a glob
+// pattern such as worker-*.
+func loadNameList(filename string) (nameList, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	list := nameList{}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("%s: line %d: invalid pattern %q", filename, lineNo, name)
+		}
+		list = append(list, name)
+	}
+	return list, scanner.Err()
+}
+
+// matches reports whether name equals or matches any entry of the list.
+func (l nameList) matches(name string) bool {
+	for _, pattern := range l {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
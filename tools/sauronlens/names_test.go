@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIncludeExcludeFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	include, err := loadNameList(write("include", "# web tier\nhttpd\n\n  worker-*  \nmdnsd\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exclude, err := loadNameList(write("exclude", "# noisy\nworker-debug\nmdnsd\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (nameList{"httpd", "worker-*", "mdnsd"}); !slices.Equal(include, want) {
+		t.Errorf("include list = %q, want %q", include, want)
+	}
+
+	opts := testOptions()
+	opts.include, opts.exclude = include, exclude
+	var lines []string
+	for _, name := range []string{"httpd", "worker-1", "worker-debug", "mdnsd", "sshd"} {
+		lines = append(lines, logLine(1, name, "Running", 1, 1, "2025-02-21T12:00:00Z"))
+	}
+	// mdnsd is on both lists; the denylist wins.
+	if got := sortedNames(aggregate(t, opts, lines...), sortName); !slices.Equal(got, []string{"httpd", "worker-1"}) {
+		t.Errorf("aggregated %v, want httpd and worker-1", got)
+	}
+
+	if _, err := loadNameList(write("bad", "worker-[\n")); err == nil {
+		t.Error("loadNameList accepted an invalid pattern")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// parseBatchSize is how many lines are handed to a parse worker at once.
+const parseBatchSize = 1024
+
+// parseBatch is a run of consecutive input lines and, once a worker is done
+// with it, their parse results.
+type parseBatch struct {
+	seq     int // position of the batch in the input
+	first   int // line number of lines[0]
+	lines   []string
+	entries []*parse.LogEntry
+	errs    []error
+}
+
+// aggregateParallel is aggregateLogs with parsing spread over opts.workers
+// goroutines. Parsed batches are merged strictly in input order by the
+// calling goroutine, so the result is identical to the sequential path.
+func aggregateParallel(stats map[string]parse.ProcessStats, scanner lineScanner, opts options) error {
+	todo := make(chan *parseBatch, opts.workers)
+	done := make(chan *parseBatch, opts.workers)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	var scanErr error
+	go func() {
+		defer close(todo)
+		batch := &parseBatch{first: 1}
+		lineNo := 0
+		send := func() bool {
+			select {
+			case todo <- batch:
+			case <-quit:
+				return false
+			}
+			batch = &parseBatch{seq: batch.seq + 1, first: lineNo + 1}
+			return true
+		}
+		for scanner.Scan() {
+			lineNo++
+			batch.lines = append(batch.lines, scanner.Text())
+			if len(batch.lines) == parseBatchSize && !send() {
+				return
+			}
+		}
+		scanErr = scanner.Err()
+		if len(batch.lines) > 0 {
+			send()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range todo {
+				batch.entries = make([]*parse.LogEntry, len(batch.lines))
+				batch.errs = make([]error, len(batch.lines))
+				for i, line := range batch.lines {
+					batch.entries[i], batch.errs[i] = opts.parseLine(line)
+				}
+				select {
+				case done <- batch:
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	pending := make(map[int]*parseBatch)
+	next := 0
+	for batch := range done {
+		pending[batch.seq] = batch
+		for b, ok := pending[next]; ok; b, ok = pending[next] {
+			delete(pending, next)
+			next++
+			for i, line := range b.lines {
+				if err := mergeEntry(stats, line, b.first+i, b.entries[i], b.errs[i], opts); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	// done is only closed after the reader finished, so scanErr is set.
+	return scanErr
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParallelMatchesSequential(t *testing.T) {
+	// Several batches of interleaved processes with malformed lines mixed
+	// in; EWMAs and retained samples depend on the merge order.
+	start := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	var b strings.Builder
+	for i := range 3*parseBatchSize + 17 {
+		if i%97 == 0 {
+			b.WriteString("garbage\n")
+			continue
+		}
+		name := fmt.Sprintf("proc%d", i%5)
+		at := start.Add(time.Duration(i) * time.Second).Format(time.RFC3339)
+		b.WriteString(logLine(i%5+1, name, "Running", float64(i%50)+0.5, float64(i%7), at) + "\n")
+	}
+	log := b.String()
+
+	run := func(workers int) (any, any) {
+		opts := testOptions()
+		opts.workers = workers
+		opts.retainSamples = true
+		opts.ewmaAlpha = 0.3
+		stats, skips, err := processLogs(strings.NewReader(log), opts)
+		if err != nil {
+			t.Fatalf("--workers %d: %v", workers, err)
+		}
+		return stats, skips
+	}
+	wantStats, wantSkips := run(1)
+	for _, workers := range []int{2, 4, 7} {
+		stats, skips := run(workers)
+		if !reflect.DeepEqual(stats, wantStats) {
+			t.Errorf("--workers %d: stats differ from the sequential path", workers)
+		}
+		if !reflect.DeepEqual(skips, wantSkips) {
+			t.Errorf("--workers %d: skips %+v, want %+v", workers, skips, wantSkips)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package parse parses Sauron process log lines and aggregates them into
+// per-process statistics.
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogEntry is a single parsed log line.
+type LogEntry struct {
+	PID       int // 0 when the PID field is malformed
+	Name      string
+	Threads   int // -1 when the thread field is missing or malformed
+	State     string
+	CPU       float64
+	Memory    float64 // RSS in MB
+	PSS       float64 // PSS in MB
+	VSZ       float64 // VSZ in MB
+	Uptime    float64 // seconds since the process started; -1 when missing or malformed
+	Host      string  // "" when the line has no Host field
+	Timestamp time.Time
+}
+
+// Format describes how a log line is split into fields and each field into
+// a key and a value.
+type Format struct {
+	FieldSep   string // between fields, " | " by default
+	KVSep      string // between a field's key and value, ": " by default
+	TimeLayout string // layout of Last Checked; "" detects one of TimeLayouts
+	Lenient    bool   // treat a missing or empty RSS, VSZ, PSS or CPU as 0 instead of rejecting the line
+}
+
+// DefaultFormat is the format written by the Sauron daemon:
+//
+//	PID: 8770 | Name: httpd | State: Running | ... | Last Checked: 2025-02-21T12:41:52.346Z
+var DefaultFormat = Format{FieldSep: " | ", KVSep: ": "}
+
+// Fields splits line into its fields, keyed by the text before KVSep. Fields
+// without a separator are ignored, so the order and number of fields do not
+// matter.
+func (f Format) Fields(line string) map[string]string {
+	fields := make(map[string]string)
+	f.each(line, func(key, value string) { fields[key] = value })
+	return fields
+}
+
+// each calls fn with the trimmed key and value of every field of line, in
+// order. It slices line in place rather than splitting it, so parsing a line
+// does not allocate per field.
+func (f Format) each(line string, fn func(key, value string)) {
+	if f.FieldSep == "" {
+		// strings.Split semantics: every UTF-8 sequence is a field.
+		for _, part := range strings.Split(line, "") {
+			if key, value, ok := strings.Cut(part, f.KVSep); ok {
+				fn(strings.TrimSpace(key), strings.TrimSpace(value))
+			}
+		}
+		return
+	}
+	for more := true; more; {
+		var part string
+		part, line, more = strings.Cut(line, f.FieldSep)
+		if key, value, ok := strings.Cut(part, f.KVSep); ok {
+			fn(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+}
+
+// field is the value of a field parse reads, and whether the line had it.
+type field struct {
+	value string
+	ok    bool
+}
+
+// ParseLogEntry parses a single log line in DefaultFormat into a LogEntry
+// struct.
+func ParseLogEntry(line string) (*LogEntry, error) {
+	return DefaultFormat.Parse(line)
+}
+
+// Parse parses a single log line into a LogEntry struct. Fields are matched
+// by key, so a line with its fields reordered parses the same.
+func (f Format) Parse(line string) (*LogEntry, error) {
+	return f.parse(line, &TimeParser{Layout: f.TimeLayout})
+}
+
+// Parser returns a parse function for f that, unlike Parse, remembers the
+// timestamp layout detected on earlier lines. It is safe for concurrent use.
+func (f Format) Parser() func(string) (*LogEntry, error) {
+	tp := &TimeParser{Layout: f.TimeLayout}
+	return func(line string) (*LogEntry, error) { return f.parse(line, tp) }
+}
+
+func (f Format) parse(line string, tp *TimeParser) (*LogEntry, error) {
+	// Only the known keys are kept, in locals rather than a map, as this
+	// runs for every line. A repeated key keeps its last value, as in
+	// Fields.
+	var name, state, rss, vsz, pss, cpu, lastChecked field
+	var pidStr, threadsStr, uptimeStr, host string
+	nfields := 0
+	f.each(strings.TrimSpace(line), func(key, value string) {
+		nfields++
+		switch key {
+		case "PID":
+			pidStr = value
+		case "Threads":
+			threadsStr = value
+		case "Uptime (sec)":
+			uptimeStr = value
+		case "Host":
+			host = value
+		case "Name":
+			name = field{value, true}
+		case "State":
+			state = field{value, true}
+		case "RSS (MB)":
+			rss = field{value, true}
+		case "VSZ (MB)":
+			vsz = field{value, true}
+		case "PSS (MB)":
+			pss = field{value, true}
+		case "CPU (%)":
+			cpu = field{value, true}
+		case "Last Checked":
+			lastChecked = field{value, true}
+		}
+	})
+	if nfields == 0 {
+		return nil, fmt.Errorf("no %q separated fields", f.KVSep)
+	}
+
+	// PID is not needed to aggregate by name, so a malformed one is left
+	// as 0 rather than rejecting the line.
+	pid, _ := strconv.Atoi(pidStr)
+
+	// Threads is likewise optional: a bad value only drops that metric.
+	threads := -1
+	if n, err := strconv.Atoi(threadsStr); err == nil && n >= 0 {
+		threads = n
+	}
+
+	uptime := -1.0
+	if v, err := strconv.ParseFloat(uptimeStr, 64); err == nil && v >= 0 {
+		uptime = v
+	}
+
+	if !name.ok {
+		return nil, fmt.Errorf("missing process name")
+	}
+	if !state.ok {
+		return nil, fmt.Errorf("missing process state")
+	}
+	memory, err := f.floatField(rss, "RSS")
+	if err != nil {
+		return nil, err
+	}
+	vszMB, err := f.floatField(vsz, "VSZ")
+	if err != nil {
+		return nil, err
+	}
+	pssMB, err := f.floatField(pss, "PSS")
+	if err != nil {
+		return nil, err
+	}
+	cpuPct, err := f.floatField(cpu, "CPU")
+	if err != nil {
+		return nil, err
+	}
+
+	if !lastChecked.ok {
+		return nil, fmt.Errorf("missing timestamp")
+	}
+	timestamp, err := tp.Parse(lastChecked.value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %v", err)
+	}
+
+	return &LogEntry{
+		PID:       pid,
+		Name:      name.value,
+		Threads:   threads,
+		State:     state.value,
+		CPU:       cpuPct,
+		Memory:    memory,
+		PSS:       pssMB,
+		VSZ:       vszMB,
+		Uptime:    uptime,
+		Host:      host,
+		Timestamp: timestamp,
+	}, nil
+}
+
+// floatField parses a numeric field, naming it label in errors. A trailing %
+// is allowed. The field is required unless f is lenient, in which case a
+// missing or empty value reads as 0; a malformed one is still an error.
+func (f Format) floatField(fd field, label string) (float64, error) {
+	if f.Lenient && fd.value == "" {
+		return 0, nil
+	}
+	if !fd.ok {
+		return 0, fmt.Errorf("missing %s", label)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(fd.value, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value: %v", label, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,176 @@
+package parse
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleLine = "PID: 8770 | Name: httpd | State: Running | Threads: 4 | RSS (MB): 10.5 | VSZ (MB): 20.0 | PSS (MB): 5.0 | CPU (%): 50.0 | Uptime (sec): 100 | Last Checked: 2025-02-21T12:41:52.346Z"
+
+// splitParse is the Split and map based parser Format.parse replaced, kept
+// to check that the rewrite parses every line the same.
+func splitParse(f Format, line string) (*LogEntry, error) {
+	fields := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimSpace(line), f.FieldSep) {
+		if key, value, ok := strings.Cut(part, f.KVSep); ok {
+			fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no %q separated fields", f.KVSep)
+	}
+	pid, _ := strconv.Atoi(fields["PID"])
+	threads := -1
+	if n, err := strconv.Atoi(fields["Threads"]); err == nil && n >= 0 {
+		threads = n
+	}
+	uptime := -1.0
+	if v, err := strconv.ParseFloat(fields["Uptime (sec)"], 64); err == nil && v >= 0 {
+		uptime = v
+	}
+	name, ok := fields["Name"]
+	if !ok {
+		return nil, fmt.Errorf("missing process name")
+	}
+	state, ok := fields["State"]
+	if !ok {
+		return nil, fmt.Errorf("missing process state")
+	}
+	number := func(key, label string) (float64, error) {
+		value, ok := fields[key]
+		if f.Lenient && value == "" {
+			return 0, nil
+		}
+		if !ok {
+			return 0, fmt.Errorf("missing %s", label)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value: %v", label, err)
+		}
+		return v, nil
+	}
+	memory, err := number("RSS (MB)", "RSS")
+	if err != nil {
+		return nil, err
+	}
+	vsz, err := number("VSZ (MB)", "VSZ")
+	if err != nil {
+		return nil, err
+	}
+	pss, err := number("PSS (MB)", "PSS")
+	if err != nil {
+		return nil, err
+	}
+	cpu, err := number("CPU (%)", "CPU")
+	if err != nil {
+		return nil, err
+	}
+	tsStr, ok := fields["Last Checked"]
+	if !ok {
+		return nil, fmt.Errorf("missing timestamp")
+	}
+	timestamp, err := (&TimeParser{Layout: f.TimeLayout}).Parse(tsStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %v", err)
+	}
+	return &LogEntry{PID: pid, Name: name, Threads: threads, State: state, CPU: cpu, Memory: memory,
+		PSS: pss, VSZ: vsz, Uptime: uptime, Host: fields["Host"], Timestamp: timestamp}, nil
+}
+
+func TestParseMatchesSplitParser(t *testing.T) {
+	lenient := DefaultFormat
+	lenient.Lenient = true
+	custom := Format{FieldSep: ";", KVSep: "="}
+
+	tests := []struct {
+		name   string
+		format Format
+		line   string
+	}{
+		{"default", DefaultFormat, sampleLine},
+		{"crlf", DefaultFormat, sampleLine + "\r\n"},
+		{"leading space", DefaultFormat, "  " + sampleLine},
+		{"reordered", DefaultFormat, "Name: httpd | Last Checked: 2025-02-21 12:41:52 | CPU (%): 1% | PSS (MB): 1 | VSZ (MB): 2 | RSS (MB): 3 | State: S"},
+		{"host", DefaultFormat, "Host: web-1 | " + sampleLine},
+		{"duplicate key", DefaultFormat, sampleLine + " | Name: nginx"},
+		{"missing pss", DefaultFormat, strings.Replace(sampleLine, " | PSS (MB): 5.0", "", 1)},
+		{"missing pss lenient", lenient, strings.Replace(sampleLine, " | PSS (MB): 5.0", "", 1)},
+		{"empty vsz lenient", lenient, strings.Replace(sampleLine, "VSZ (MB): 20.0", "VSZ (MB): ", 1)},
+		{"missing name", DefaultFormat, strings.Replace(sampleLine, "Name: httpd | ", "", 1)},
+		{"missing timestamp", DefaultFormat, strings.Split(sampleLine, " | Last Checked")[0]},
+		{"bad cpu", DefaultFormat, strings.Replace(sampleLine, "CPU (%): 50.0", "CPU (%): lots", 1)},
+		{"bad pid and threads", DefaultFormat, strings.Replace(strings.Replace(sampleLine, "8770", "x", 1), "Threads: 4", "Threads: -2", 1)},
+		{"trailing separator", DefaultFormat, sampleLine + " | "},
+		{"doubled separator", DefaultFormat, strings.Replace(sampleLine, " | State", " |  | State", 1)},
+		{"separator in value", DefaultFormat, strings.Replace(sampleLine, "Name: httpd", "Name: a: b", 1)},
+		{"no fields", DefaultFormat, "not a log line"},
+		{"empty", DefaultFormat, ""},
+		{"custom separators", custom, "Name=a;State=R;RSS (MB)=1;VSZ (MB)=2;PSS (MB)=3;CPU (%)=4;Last Checked=2025-02-21T12:41:52Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := tt.format.Parse(tt.line)
+			want, wantErr := splitParse(tt.format, tt.line)
+			if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+				t.Fatalf("error = %v, want %v", gotErr, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("entry = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseLenientMissingPSS(t *testing.T) {
+	line := strings.Replace(sampleLine, " | PSS (MB): 5.0", "", 1)
+	if _, err := DefaultFormat.Parse(line); err == nil || err.Error() != "missing PSS" {
+		t.Fatalf("strict Parse error = %v, want missing PSS", err)
+	}
+	lenient := DefaultFormat
+	lenient.Lenient = true
+	entry, err := lenient.Parse(line)
+	if err != nil {
+		t.Fatalf("lenient Parse: %v", err)
+	}
+	if entry.PSS != 0 || entry.Memory != 10.5 {
+		t.Errorf("PSS, RSS = %v, %v; want 0, 10.5", entry.PSS, entry.Memory)
+	}
+}
+
+func TestParseCustomAndReorderedFields(t *testing.T) {
+	want := &LogEntry{PID: 42, Name: "httpd", Threads: 3, State: "Running", CPU: 1.5, Memory: 10, PSS: 4, VSZ: 20,
+		Uptime: 60, Timestamp: time.Date(2025, 2, 21, 12, 41, 52, 0, time.UTC)}
+	tests := []struct {
+		name   string
+		format Format
+		line   string
+	}{
+		{"custom separators", Format{FieldSep: ",", KVSep: "="},
+			"PID=42,Name=httpd,State=Running,Threads=3,RSS (MB)=10,VSZ (MB)=20,PSS (MB)=4,CPU (%)=1.5,Uptime (sec)=60,Last Checked=2025-02-21T12:41:52Z"},
+		{"reordered", DefaultFormat,
+			"Last Checked: 2025-02-21T12:41:52Z | CPU (%): 1.5 | Name: httpd | PSS (MB): 4 | Uptime (sec): 60 | RSS (MB): 10 | PID: 42 | VSZ (MB): 20 | Threads: 3 | State: Running"},
+	}
+	for _, tt := range tests {
+		got, err := tt.format.Parse(tt.line)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: entry = %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func BenchmarkParseLogEntry(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ParseLogEntry(sampleLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
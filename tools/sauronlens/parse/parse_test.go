@@ -0,0 +1,32 @@
+package parse_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestProcessLogs(t *testing.T) {
+	log := strings.Join([]string{
+		"PID: 8770 | Name: httpd | State: Running | Threads: 4 | RSS (MB): 10 | VSZ (MB): 20 | PSS (MB): 5 | CPU (%): 1.5 | Uptime (sec): 100 | Last Checked: 2025-02-21T12:41:52Z",
+		"not a log line",
+		"PID: 8770 | Name: httpd | State: Running | Threads: 4 | RSS (MB): 30 | VSZ (MB): 20 | PSS (MB): 7 | CPU (%): 0.5 | Uptime (sec): 160 | Last Checked: 2025-02-21T12:42:52Z",
+		"PID: 5998 | Name: mdnsd | State: Sleeping (interruptible) | Threads: 3 | RSS (MB): 4.5 | VSZ (MB): 9 | PSS (MB): 2 | CPU (%): 0 | Uptime (sec): 50 | Last Checked: 2025-02-21T12:41:52Z",
+	}, "\n")
+
+	stats, err := parse.ProcessLogs(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got %d processes, want httpd and mdnsd", len(stats))
+	}
+	httpd := stats["httpd"]
+	if httpd.Count != 2 || httpd.MaxMemory != 30 || httpd.LatestPSS != 7 || httpd.TotalCPU != 2 {
+		t.Errorf("httpd = %d samples, max RSS %v, latest PSS %v, total CPU %v", httpd.Count, httpd.MaxMemory, httpd.LatestPSS, httpd.TotalCPU)
+	}
+	if mdnsd := stats["mdnsd"]; mdnsd.State != "Sleeping (interruptible)" || mdnsd.LatestMemory != 4.5 {
+		t.Errorf("mdnsd = state %q, RSS %v", mdnsd.State, mdnsd.LatestMemory)
+	}
+}
@@ -0,0 +1,49 @@
+package parse
+
+import "math/rand/v2"
+
+// ReservoirSize is the most samples kept in ProcessStats.Reservoir.
+const ReservoirSize = 1024
+
+// reservoirAdd adds s, the seen-th sample (0-based) of a process, to
+// reservoir using Algorithm R, so the reservoir stays a uniform random subset
+// of at most ReservoirSize samples.
+func reservoirAdd(reservoir []Sample, s Sample, seen int, rng *rand.Rand) []Sample {
+	if len(reservoir) < ReservoirSize {
+		return append(reservoir, s)
+	}
+	if j := rng.IntN(seen + 1); j < ReservoirSize {
+		reservoir[j] = s
+	}
+	return reservoir
+}
+
+// StateCode maps a logged state to its single-letter /proc code. States that
+// are already a single letter are returned unchanged.
+func StateCode(state string) byte {
+	switch state {
+	case "Running":
+		return 'R'
+	case "Sleeping (interruptible)":
+		return 'S'
+	case "Sleeping (uninterruptible)":
+		return 'D'
+	case "Stopped":
+		return 'T'
+	case "Zombie":
+		return 'Z'
+	case "Dead":
+		return 'X'
+	}
+	if len(state) == 1 {
+		return state[0]
+	}
+	return '?'
+}
+
+// abnormalState reports whether state is zombie or stopped, the states worth
+// flagging when a process moves in or out of them.
+func abnormalState(state string) bool {
+	code := StateCode(state)
+	return code == 'Z' || code == 'T'
+}
@@ -0,0 +1,306 @@
+package parse
+
+import (
+	"bufio"
+	"io"
+	"math/rand/v2"
+	"time"
+)
+
+// ProcessStats aggregates every sample of one process.
+type ProcessStats struct {
+	TotalCPU      float64
+	TotalMemory   float64
+	TotalPSS      float64
+	TotalVSZ      float64
+	MinMemory     float64
+	MaxMemory     float64
+	MinPSS        float64
+	MaxPSS        float64
+	MinVSZ        float64
+	MaxVSZ        float64
+	MinCPU        float64
+	MaxCPU        float64
+	Count         int
+	TotalThreads  int
+	MinThreads    int
+	MaxThreads    int
+	ThreadSamples int // samples with a valid thread count
+	MaxMemoryTime string
+	MaxPSSTime    string
+	MaxVSZTime    string
+	MaxCPUTime    string
+	MaxMemoryAt   time.Time // MaxMemoryTime unformatted, keeping the logged UTC offset
+	MaxPSSAt      time.Time // MaxPSSTime unformatted
+	MaxCPUAt      time.Time // MaxCPUTime unformatted
+	LatestCPU     float64
+	LatestMemory  float64
+	LatestPSS     float64
+	LatestVSZ     float64
+	LatestTime    time.Time
+	FirstTime     time.Time
+	PrevCPU       float64        // CPU of the sample before the latest one
+	PrevMemory    float64        // RSS of the sample before the latest one
+	PrevTime      time.Time      // zero until a second sample in time order
+	LatestUptime  float64        // latest valid uptime in seconds; -1 if none was logged
+	MinUptime     float64        // smallest valid uptime in seconds; -1 if none was logged
+	MaxUptime     float64        // largest valid uptime in seconds; -1 if none was logged
+	Restarts      int            // times the uptime went down between samples
+	Duplicates    int            // entries dropped by Options.Dedup
+	State         string         // state of the latest sample
+	Transitions   int            // state changes between consecutive samples
+	Abnormal      int            // transitions into or out of zombie or stopped
+	PrevState     string         // state before the last transition
+	LastChange    time.Time      // time of the last transition
+	Name          string         // process name of the latest sample
+	Host          string         // host of the latest sample; "" if none was logged
+	Names         map[string]int // sample count per name; only kept when Options.TrackNames is set
+	PIDs          map[int]bool   // PIDs seen, excluding unparsed ones
+	DistinctPIDs  int            // len(PIDs), kept so reports need not read the map
+	SpreadCPU     Welford        // running variance of CPU
+	SpreadMemory  Welford        // running variance of RSS
+	SpreadPSS     Welford        // running variance of PSS
+	EwmaCPU       float64        // only maintained when Options.EWMAAlpha is set
+	EwmaMemory    float64        // only maintained when Options.EWMAAlpha is set
+	EwmaPSS       float64        // only maintained when Options.EWMAAlpha is set
+	Samples       []Sample       // only populated when Options.RetainSamples is set
+	Reservoir     []Sample       // uniform random subset of samples, only kept when Options.Reservoir is set
+}
+
+// Velocity returns the change in CPU and RSS between the last two samples,
+// per minute. ok is false until there are two samples in time order.
+func (s ProcessStats) Velocity() (cpu, memory float64, ok bool) {
+	dt := s.LatestTime.Sub(s.PrevTime).Minutes()
+	if s.PrevTime.IsZero() || dt <= 0 {
+		return 0, 0, false
+	}
+	return (s.LatestCPU - s.PrevCPU) / dt, (s.LatestMemory - s.PrevMemory) / dt, true
+}
+
+// Sample is a single retained observation of a process.
+type Sample struct {
+	Time   time.Time
+	CPU    float64
+	Memory float64 // RSS in MB
+	PSS    float64 // PSS in MB
+	State  string
+}
+
+// Options controls the optional parts of aggregation. The zero value keeps
+// only running totals, minimums, maximums and latest values.
+type Options struct {
+	EWMAAlpha     float64    // weight of the newest sample in EWMAs, applied in read order; 0 disables
+	TrackNames    bool       // count samples per name, for keys that are not names
+	RetainSamples bool       // keep every sample in ProcessStats.Samples
+	Reservoir     bool       // keep a uniform random subset in ProcessStats.Reservoir
+	Dedup         bool       // drop entries repeating the latest name and timestamp
+	Rand          *rand.Rand // drives reservoir sampling; required with Reservoir
+}
+
+// Update merges entry into the stats aggregated under key.
+func Update(stats map[string]ProcessStats, key string, entry *LogEntry, opts Options) {
+	tsStr := entry.Timestamp.Format("2006-01-02 15:04:05")
+	stat, exists := stats[key]
+	if exists && opts.Dedup && entry.Name == stat.Name && entry.Timestamp.Equal(stat.LatestTime) {
+		// The last duplicate wins for the latest values but is not counted
+		// again.
+		stat.LatestCPU = entry.CPU
+		stat.LatestMemory = entry.Memory
+		stat.LatestPSS = entry.PSS
+		stat.LatestVSZ = entry.VSZ
+		stat.Duplicates++
+		stats[key] = stat
+		return
+	}
+	if !exists {
+		stat = ProcessStats{
+			State:         entry.State,
+			Name:          entry.Name,
+			Host:          entry.Host,
+			MinMemory:     entry.Memory,
+			MaxMemory:     entry.Memory,
+			MaxMemoryTime: tsStr,
+			MaxMemoryAt:   entry.Timestamp,
+			MinPSS:        entry.PSS,
+			MaxPSS:        entry.PSS,
+			MaxPSSTime:    tsStr,
+			MaxPSSAt:      entry.Timestamp,
+			MinVSZ:        entry.VSZ,
+			MaxVSZ:        entry.VSZ,
+			MaxVSZTime:    tsStr,
+			MinCPU:        entry.CPU,
+			MaxCPU:        entry.CPU,
+			MaxCPUTime:    tsStr,
+			MaxCPUAt:      entry.Timestamp,
+			LatestCPU:     entry.CPU,
+			LatestMemory:  entry.Memory,
+			LatestPSS:     entry.PSS,
+			LatestVSZ:     entry.VSZ,
+			LatestTime:    entry.Timestamp,
+			LatestUptime:  entry.Uptime,
+			MinUptime:     entry.Uptime,
+			MaxUptime:     entry.Uptime,
+			FirstTime:     entry.Timestamp,
+			EwmaCPU:       entry.CPU,
+			EwmaMemory:    entry.Memory,
+			EwmaPSS:       entry.PSS,
+		}
+	} else if a := opts.EWMAAlpha; a > 0 {
+		// Samples are weighted in the order they are read, so the result
+		// is only meaningful for input in time order, as Sauron writes it.
+		stat.EwmaCPU = a*entry.CPU + (1-a)*stat.EwmaCPU
+		stat.EwmaMemory = a*entry.Memory + (1-a)*stat.EwmaMemory
+		stat.EwmaPSS = a*entry.PSS + (1-a)*stat.EwmaPSS
+	}
+
+	// Aggregate
+	stat.SpreadCPU.Add(entry.CPU)
+	stat.SpreadMemory.Add(entry.Memory)
+	stat.SpreadPSS.Add(entry.PSS)
+	stat.TotalCPU += entry.CPU
+	stat.TotalMemory += entry.Memory
+	stat.TotalPSS += entry.PSS
+	stat.TotalVSZ += entry.VSZ
+
+	// Min/Max
+	if entry.Memory < stat.MinMemory {
+		stat.MinMemory = entry.Memory
+	}
+	if entry.Memory > stat.MaxMemory {
+		stat.MaxMemory = entry.Memory
+		stat.MaxMemoryTime = tsStr
+		stat.MaxMemoryAt = entry.Timestamp
+	}
+	if entry.PSS < stat.MinPSS {
+		stat.MinPSS = entry.PSS
+	}
+	if entry.PSS > stat.MaxPSS {
+		stat.MaxPSS = entry.PSS
+		stat.MaxPSSTime = tsStr
+		stat.MaxPSSAt = entry.Timestamp
+	}
+	if entry.VSZ < stat.MinVSZ {
+		stat.MinVSZ = entry.VSZ
+	}
+	if entry.VSZ > stat.MaxVSZ {
+		stat.MaxVSZ = entry.VSZ
+		stat.MaxVSZTime = tsStr
+	}
+	if entry.CPU < stat.MinCPU {
+		stat.MinCPU = entry.CPU
+	}
+	if entry.CPU > stat.MaxCPU {
+		stat.MaxCPU = entry.CPU
+		stat.MaxCPUTime = tsStr
+		stat.MaxCPUAt = entry.Timestamp
+	}
+
+	// Threads
+	if entry.Threads >= 0 {
+		if stat.ThreadSamples == 0 || entry.Threads < stat.MinThreads {
+			stat.MinThreads = entry.Threads
+		}
+		if stat.ThreadSamples == 0 || entry.Threads > stat.MaxThreads {
+			stat.MaxThreads = entry.Threads
+		}
+		stat.TotalThreads += entry.Threads
+		stat.ThreadSamples++
+	}
+
+	if entry.Uptime >= 0 {
+		if stat.MinUptime < 0 || entry.Uptime < stat.MinUptime {
+			stat.MinUptime = entry.Uptime
+		}
+		if entry.Uptime > stat.MaxUptime {
+			stat.MaxUptime = entry.Uptime
+		}
+	}
+
+	// Earliest and latest are compared rather than taken from file position,
+	// so out-of-order lines still give the correct window.
+	if entry.Timestamp.Before(stat.FirstTime) {
+		stat.FirstTime = entry.Timestamp
+	}
+
+	// Latest. State changes are only counted between samples in time
+	// order; a line older than the latest one can't be placed in sequence.
+	if entry.Timestamp.After(stat.LatestTime) {
+		if entry.State != stat.State {
+			stat.Transitions++
+			if abnormalState(entry.State) || abnormalState(stat.State) {
+				stat.Abnormal++
+			}
+			stat.PrevState = stat.State
+			stat.LastChange = entry.Timestamp
+			stat.State = entry.State
+		}
+		stat.PrevCPU = stat.LatestCPU
+		stat.PrevMemory = stat.LatestMemory
+		stat.PrevTime = stat.LatestTime
+		stat.LatestCPU = entry.CPU
+		stat.LatestMemory = entry.Memory
+		stat.LatestPSS = entry.PSS
+		stat.LatestVSZ = entry.VSZ
+		stat.LatestTime = entry.Timestamp
+		stat.Name = entry.Name
+		stat.Host = entry.Host
+		// A process that restarted has a lower uptime than when it was
+		// last seen.
+		if entry.Uptime >= 0 {
+			if stat.LatestUptime >= 0 && entry.Uptime < stat.LatestUptime {
+				stat.Restarts++
+			}
+			stat.LatestUptime = entry.Uptime
+		}
+	}
+
+	if entry.PID > 0 && !stat.PIDs[entry.PID] {
+		if stat.PIDs == nil {
+			stat.PIDs = make(map[int]bool)
+		}
+		stat.PIDs[entry.PID] = true
+		stat.DistinctPIDs++
+	}
+
+	if opts.TrackNames {
+		if stat.Names == nil {
+			stat.Names = make(map[string]int)
+		}
+		stat.Names[entry.Name]++
+	}
+
+	sample := Sample{
+		Time:   entry.Timestamp,
+		CPU:    entry.CPU,
+		Memory: entry.Memory,
+		PSS:    entry.PSS,
+		State:  entry.State,
+	}
+	if opts.RetainSamples {
+		stat.Samples = append(stat.Samples, sample)
+	}
+	if opts.Reservoir {
+		stat.Reservoir = reservoirAdd(stat.Reservoir, sample, stat.Count, opts.Rand)
+	}
+
+	stat.Count++
+	stats[key] = stat
+}
+
+// ProcessLogs reads log lines from r and aggregates them by process name.
+// Lines that fail to parse are skipped.
+func ProcessLogs(r io.Reader) (map[string]ProcessStats, error) {
+	stats := make(map[string]ProcessStats)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		entry, err := ParseLogEntry(scanner.Text())
+		if err != nil {
+			continue
+		}
+		Update(stats, entry.Name, entry, Options{})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,217 @@
+package parse
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// mustParse parses line in DefaultFormat and fails the test on error.
+func mustParse(t *testing.T, line string) *LogEntry {
+	t.Helper()
+	entry, err := ParseLogEntry(line)
+	if err != nil {
+		t.Fatalf("ParseLogEntry(%q): %v", line, err)
+	}
+	return entry
+}
+
+func TestUpdateAggregatesVSZSeparately(t *testing.T) {
+	stats := map[string]ProcessStats{}
+	for _, line := range []string{
+		"Name: httpd | State: Running | RSS (MB): 10 | VSZ (MB):   626.2  | PSS (MB): 5 | CPU (%): 1 | Last Checked: 2025-02-21T12:41:52Z",
+		"Name: httpd | State: Running | RSS (MB): 30 | VSZ (MB):  400 | PSS (MB): 5 | CPU (%): 1 | Last Checked: 2025-02-21T12:42:52Z",
+	} {
+		entry := mustParse(t, line)
+		Update(stats, entry.Name, entry, Options{})
+	}
+	stat := stats["httpd"]
+	if stat.MinVSZ != 400 || stat.MaxVSZ != 626.2 || stat.TotalVSZ != 1026.2 || stat.LatestVSZ != 400 {
+		t.Errorf("VSZ min/max/total/latest = %v/%v/%v/%v, want 400/626.2/1026.2/400", stat.MinVSZ, stat.MaxVSZ, stat.TotalVSZ, stat.LatestVSZ)
+	}
+	if stat.MinMemory != 10 || stat.MaxMemory != 30 || stat.TotalMemory != 40 || stat.LatestMemory != 30 {
+		t.Errorf("RSS min/max/total/latest = %v/%v/%v/%v, want 10/30/40/30", stat.MinMemory, stat.MaxMemory, stat.TotalMemory, stat.LatestMemory)
+	}
+	if stat.MaxVSZTime != "2025-02-21 12:41:52" {
+		t.Errorf("MaxVSZTime = %q, want the first sample", stat.MaxVSZTime)
+	}
+}
+
+// testStart is the time of the first entry built by entryAt.
+var testStart = time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+
+// entryAt returns an entry of process name sampled minute minutes after
+// testStart.
+func entryAt(name, state string, rss float64, minute int) *LogEntry {
+	return &LogEntry{
+		PID:       1,
+		Name:      name,
+		Threads:   -1,
+		State:     state,
+		Memory:    rss,
+		Uptime:    -1,
+		Timestamp: testStart.Add(time.Duration(minute) * time.Minute),
+	}
+}
+
+func TestUpdateStateTransitions(t *testing.T) {
+	stats := map[string]ProcessStats{}
+	for i, state := range []string{"S", "S", "R", "Z", "S", "S"} {
+		Update(stats, "foo", entryAt("foo", state, 1, i), Options{})
+	}
+	// A line older than the latest sample does not change the state.
+	Update(stats, "foo", entryAt("foo", "D", 1, 1), Options{})
+
+	stat := stats["foo"]
+	if stat.State != "S" || stat.Transitions != 3 || stat.Abnormal != 2 {
+		t.Errorf("state %q, %d transitions (%d abnormal); want S, 3 (2)", stat.State, stat.Transitions, stat.Abnormal)
+	}
+	if want := testStart.Add(4 * time.Minute); stat.PrevState != "Z" || !stat.LastChange.Equal(want) {
+		t.Errorf("last change from %q at %v, want from Z at %v", stat.PrevState, stat.LastChange, want)
+	}
+}
+
+func TestUpdateSingleEntrySetsMaxTimes(t *testing.T) {
+	stats := map[string]ProcessStats{}
+	Update(stats, "httpd", mustParse(t, sampleLine), Options{})
+	stat := stats["httpd"]
+	const want = "2025-02-21 12:41:52"
+	for label, got := range map[string]string{
+		"MaxMemoryTime": stat.MaxMemoryTime,
+		"MaxPSSTime":    stat.MaxPSSTime,
+		"MaxVSZTime":    stat.MaxVSZTime,
+		"MaxCPUTime":    stat.MaxCPUTime,
+	} {
+		if got != want {
+			t.Errorf("%s = %q, want %q", label, got, want)
+		}
+	}
+	ts := stat.LatestTime
+	if !stat.MaxMemoryAt.Equal(ts) || !stat.MaxPSSAt.Equal(ts) || !stat.MaxCPUAt.Equal(ts) {
+		t.Errorf("Max*At = %v, %v, %v; want %v", stat.MaxMemoryAt, stat.MaxPSSAt, stat.MaxCPUAt, ts)
+	}
+}
+
+// updateUptimes feeds one entry per uptime, a minute apart, and returns the
+// resulting stats.
+func updateUptimes(uptimes ...float64) ProcessStats {
+	stats := map[string]ProcessStats{}
+	for i, up := range uptimes {
+		entry := entryAt("foo", "S", 1, i)
+		entry.Uptime = up
+		Update(stats, "foo", entry, Options{})
+	}
+	return stats["foo"]
+}
+
+func TestUpdateCountsRestarts(t *testing.T) {
+	stat := updateUptimes(100, 110, 5)
+	if stat.Restarts != 1 || stat.LatestUptime != 5 {
+		t.Errorf("restarts %d, latest uptime %v; want 1 and 5", stat.Restarts, stat.LatestUptime)
+	}
+	// A missing uptime is neither a restart nor the latest one.
+	if stat := updateUptimes(100, -1, 160); stat.Restarts != 0 || stat.LatestUptime != 160 {
+		t.Errorf("with a missing uptime: restarts %d, latest %v; want 0 and 160", stat.Restarts, stat.LatestUptime)
+	}
+}
+
+func TestUpdateDedup(t *testing.T) {
+	stats := map[string]ProcessStats{}
+	first := entryAt("foo", "S", 10, 0)
+	repeat := entryAt("foo", "S", 30, 0)
+	next := entryAt("foo", "S", 20, 1)
+	for _, entry := range []*LogEntry{first, repeat, next} {
+		Update(stats, "foo", entry, Options{Dedup: true})
+	}
+	stat := stats["foo"]
+	if stat.Count != 2 || stat.Duplicates != 1 || stat.TotalMemory != 30 {
+		t.Errorf("count %d, duplicates %d, total RSS %v; want 2, 1, 30", stat.Count, stat.Duplicates, stat.TotalMemory)
+	}
+
+	stats = map[string]ProcessStats{}
+	Update(stats, "foo", first, Options{Dedup: true})
+	Update(stats, "foo", repeat, Options{Dedup: true})
+	if stat := stats["foo"]; stat.Count != 1 || stat.LatestMemory != 30 {
+		t.Errorf("after a duplicate: count %d, latest RSS %v; want 1 and the duplicate's 30", stat.Count, stat.LatestMemory)
+	}
+
+	stats = map[string]ProcessStats{}
+	Update(stats, "foo", first, Options{})
+	Update(stats, "foo", repeat, Options{})
+	if stat := stats["foo"]; stat.Count != 2 {
+		t.Errorf("without Dedup: count %d, want 2", stat.Count)
+	}
+}
+
+func TestUpdateEWMA(t *testing.T) {
+	stats := map[string]ProcessStats{}
+	for i, cpu := range []float64{10, 20, 40} {
+		entry := entryAt("foo", "S", 100*float64(i+1), i)
+		entry.CPU = cpu
+		Update(stats, "foo", entry, Options{EWMAAlpha: 0.5})
+	}
+	// Seeded with the first sample: 10, then 0.5*20+0.5*10 = 15, then
+	// 0.5*40+0.5*15 = 27.5. RSS goes 100, 150, 225.
+	stat := stats["foo"]
+	if math.Abs(stat.EwmaCPU-27.5) > 1e-9 || math.Abs(stat.EwmaMemory-225) > 1e-9 {
+		t.Errorf("EWMA CPU %v, RSS %v; want 27.5 and 225", stat.EwmaCPU, stat.EwmaMemory)
+	}
+}
+
+func TestUpdateOutOfOrderFirstAndLast(t *testing.T) {
+	stats := map[string]ProcessStats{}
+	for _, minute := range []int{30, 5, 90, 60, 0, 45} {
+		Update(stats, "foo", entryAt("foo", "S", float64(minute), minute), Options{})
+	}
+	stat := stats["foo"]
+	if !stat.FirstTime.Equal(testStart) || !stat.LatestTime.Equal(testStart.Add(90*time.Minute)) {
+		t.Errorf("first %v, latest %v; want %v and 90 minutes later", stat.FirstTime, stat.LatestTime, testStart)
+	}
+	// Latest values come from the latest sample, not the last line read.
+	if stat.LatestMemory != 90 {
+		t.Errorf("latest RSS %v, want 90", stat.LatestMemory)
+	}
+}
+
+func TestUpdateCountsDistinctPIDs(t *testing.T) {
+	stats := map[string]ProcessStats{}
+	for i, pid := range []int{100, 200, 100} {
+		entry := entryAt("foo", "S", 1, i)
+		entry.PID = pid
+		Update(stats, "foo", entry, Options{})
+	}
+	if stat := stats["foo"]; stat.DistinctPIDs != 2 || len(stat.PIDs) != 2 {
+		t.Errorf("DistinctPIDs = %d, PIDs = %v, want 2", stat.DistinctPIDs, stat.PIDs)
+	}
+}
+
+func TestUpdateUptimeRangeWithRestart(t *testing.T) {
+	stat := updateUptimes(100, 400, 700, 30, 330)
+	if stat.MinUptime != 30 || stat.MaxUptime != 700 || stat.LatestUptime != 330 {
+		t.Errorf("uptime min %v, max %v, latest %v; want 30, 700 and 330", stat.MinUptime, stat.MaxUptime, stat.LatestUptime)
+	}
+}
+
+func TestUpdateVelocity(t *testing.T) {
+	stats := map[string]ProcessStats{}
+	first := entryAt("foo", "S", 10, 0)
+	first.CPU = 5
+	Update(stats, "foo", first, Options{})
+	if _, _, ok := stats["foo"].Velocity(); ok {
+		t.Error("Velocity after one sample reported ok")
+	}
+
+	second := entryAt("foo", "S", 11.6, 0)
+	second.Timestamp = second.Timestamp.Add(30 * time.Second)
+	second.CPU = 2
+	Update(stats, "foo", second, Options{})
+	cpu, rss, ok := stats["foo"].Velocity()
+	if !ok || math.Abs(cpu-(-6)) > 1e-9 || math.Abs(rss-3.2) > 1e-9 {
+		t.Errorf("Velocity = %v CPU, %v MB, %v; want -6/min and 3.2/min", cpu, rss, ok)
+	}
+
+	stat := ProcessStats{PrevTime: testStart, LatestTime: testStart, PrevMemory: 1, LatestMemory: 2}
+	if _, _, ok := stat.Velocity(); ok {
+		t.Error("Velocity over a zero time gap reported ok")
+	}
+}
@@ -0,0 +1,48 @@
+package parse
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// TimeLayouts are the timestamp layouts tried, in order, when no layout is
+// configured. Layouts without a zone parse as UTC.
+var TimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// TimeParser parses timestamps with Layout or, when it is empty, with the
+// first of TimeLayouts that works. The detected layout is tried first from
+// then on, so a log in a single layout costs one parse per line. A
+// TimeParser is safe for concurrent use.
+type TimeParser struct {
+	Layout   string
+	detected atomic.Pointer[string]
+}
+
+// Parse parses value as a timestamp.
+func (tp *TimeParser) Parse(value string) (time.Time, error) {
+	if tp.Layout != "" {
+		return time.Parse(tp.Layout, value)
+	}
+	detected := tp.detected.Load()
+	if detected != nil {
+		if t, err := time.Parse(*detected, value); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range TimeLayouts {
+		if detected != nil && layout == *detected {
+			continue
+		}
+		if t, err := time.Parse(layout, value); err == nil {
+			tp.detected.Store(&layout)
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q matches none of the known layouts", value)
+}
@@ -0,0 +1,58 @@
+package parse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeParserLayouts(t *testing.T) {
+	base := time.Date(2025, 2, 21, 12, 41, 52, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"RFC3339", "2025-02-21T12:41:52Z", base},
+		{"RFC3339 with offset", "2025-02-21T14:41:52+02:00", base},
+		{"RFC3339Nano", "2025-02-21T12:41:52.346123789Z", base.Add(346123789 * time.Nanosecond)},
+		{"space separated", "2025-02-21 12:41:52", base},
+		{"space separated millis", "2025-02-21 12:41:52.346", base.Add(346 * time.Millisecond)},
+		{"no zone", "2025-02-21T12:41:52", base},
+	}
+	var tp TimeParser
+	for _, tt := range tests {
+		// One parser for every case also covers switching layouts midway.
+		got, err := tp.Parse(tt.value)
+		if err != nil {
+			t.Errorf("%s: Parse(%q): %v", tt.name, tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: Parse(%q) = %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+	if _, err := tp.Parse("21/02/2025 12:41"); err == nil {
+		t.Error("Parse accepted an unknown layout")
+	}
+}
+
+func TestTimeParserCustomLayout(t *testing.T) {
+	tp := TimeParser{Layout: "02/01/2006 15:04"}
+	got, err := tp.Parse("21/02/2025 12:41")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 2, 21, 12, 41, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Parse = %v, want %v", got, want)
+	}
+	if _, err := tp.Parse("2025-02-21T12:41:52Z"); err == nil {
+		t.Error("a custom layout fell back to the default layouts")
+	}
+
+	f := DefaultFormat
+	f.TimeLayout = tp.Layout
+	entry, err := f.Parse("Name: httpd | State: R | RSS (MB): 1 | VSZ (MB): 1 | PSS (MB): 1 | CPU (%): 1 | Last Checked: 21/02/2025 12:41")
+	if err != nil || !entry.Timestamp.Equal(got) {
+		t.Errorf("Format.Parse with TimeLayout = %v, %v", entry, err)
+	}
+}
@@ -0,0 +1,57 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SchemaFields are the keys of a Sauron log line, in the order the daemon
+// writes them.
+var SchemaFields = []string{
+	"PID", "Name", "State", "Threads", "RSS (MB)", "VSZ (MB)", "PSS (MB)", "CPU (%)", "Uptime (sec)", "Last Checked",
+}
+
+// integerFields and numberFields are the SchemaFields Validate checks the
+// values of.
+var (
+	integerFields = map[string]bool{"PID": true, "Threads": true}
+	numberFields  = map[string]bool{"RSS (MB)": true, "VSZ (MB)": true, "PSS (MB)": true, "CPU (%)": true, "Uptime (sec)": true}
+)
+
+// Validate checks that line has exactly the SchemaFields, in order, with
+// well-formed numeric values. Unlike Parse, which tolerates reordered,
+// extra and some malformed fields, the error names the first offending
+// field and its 1-based position.
+func (f Format) Validate(line string) error {
+	parts := strings.Split(strings.TrimSpace(line), f.FieldSep)
+	for i, want := range SchemaFields {
+		if i >= len(parts) {
+			return fmt.Errorf("field %d: missing %q", i+1, want)
+		}
+		key, value, ok := strings.Cut(parts[i], f.KVSep)
+		if !ok {
+			return fmt.Errorf("field %d: expected %q, got %q without %q", i+1, want, parts[i], f.KVSep)
+		}
+		if key = strings.TrimSpace(key); key != want {
+			return fmt.Errorf("field %d: expected %q, got %q", i+1, want, key)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			return fmt.Errorf("field %d: empty %q", i+1, want)
+		case integerFields[want]:
+			if _, err := strconv.Atoi(value); err != nil {
+				return fmt.Errorf("field %d: %q is not an integer: %q", i+1, want, value)
+			}
+		case numberFields[want]:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("field %d: %q is not a number: %q", i+1, want, value)
+			}
+		}
+	}
+	if len(parts) > len(SchemaFields) {
+		return fmt.Errorf("field %d: unexpected %q after %q", len(SchemaFields)+1, parts[len(SchemaFields)], SchemaFields[len(SchemaFields)-1])
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := DefaultFormat.Validate(sampleLine); err != nil {
+		t.Fatalf("valid line: %v", err)
+	}
+	if err := DefaultFormat.Validate(sampleLine + "\r\n"); err != nil {
+		t.Errorf("valid CRLF line: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"missing field", strings.Replace(sampleLine, " | PSS (MB): 5.0", "", 1), `field 7: expected "PSS (MB)", got "CPU (%)"`},
+		{"truncated", strings.Split(sampleLine, " | Uptime")[0], `field 9: missing "Uptime (sec)"`},
+		{"reordered", strings.Replace(sampleLine, "Name: httpd | State: Running", "State: Running | Name: httpd", 1), `field 2: expected "Name", got "State"`},
+		{"no separator", strings.Replace(sampleLine, "Threads: 4", "Threads 4", 1), `field 4: expected "Threads", got "Threads 4" without ": "`},
+		{"empty value", strings.Replace(sampleLine, "Name: httpd", "Name: ", 1), `field 2: empty "Name"`},
+		{"bad integer", strings.Replace(sampleLine, "PID: 8770", "PID: 87.70", 1), `field 1: "PID" is not an integer: "87.70"`},
+		{"bad number", strings.Replace(sampleLine, "CPU (%): 50.0", "CPU (%): 50%", 1), `field 8: "CPU (%)" is not a number: "50%"`},
+		{"extra field", sampleLine + " | Host: web-1", `field 11: unexpected "Host: web-1" after "Last Checked"`},
+	}
+	for _, tt := range tests {
+		err := DefaultFormat.Validate(tt.line)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: Validate error = %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package parse
+
+import "math"
+
+// Welford accumulates the mean and variance of a series in a single pass
+// using Welford's online algorithm, which stays accurate where summing
+// squares would lose precision to cancellation on large values.
+type Welford struct {
+	N    int
+	Mean float64
+	M2   float64 // sum of squared deviations from the mean
+}
+
+// Add adds x to the series.
+func (w *Welford) Add(x float64) {
+	w.N++
+	delta := x - w.Mean
+	w.Mean += delta / float64(w.N)
+	w.M2 += delta * (x - w.Mean)
+}
+
+// StdDev returns the sample standard deviation, or 0 for fewer than two
+// values.
+func (w Welford) StdDev() float64 {
+	if w.N < 2 {
+		return 0
+	}
+	return math.Sqrt(w.M2 / float64(w.N-1))
+}
+
+// CV returns the coefficient of variation, the standard deviation relative
+// to the mean. It is NaN when the mean is zero.
+func (w Welford) CV() float64 {
+	if w.Mean == 0 {
+		return math.NaN()
+	}
+	return w.StdDev() / math.Abs(w.Mean)
+}
+
+// ZScore returns how many standard deviations x lies from the mean. It is
+// NaN when the standard deviation is zero.
+func (w Welford) ZScore(x float64) float64 {
+	sd := w.StdDev()
+	if sd == 0 {
+		return math.NaN()
+	}
+	return (x - w.Mean) / sd
+}
+
+// Without returns the accumulator as it was before x was added, so a value
+// can be compared against the rest of its series.
+func (w Welford) Without(x float64) Welford {
+	if w.N <= 1 {
+		return Welford{}
+	}
+	n := w.N - 1
+	mean := (w.Mean*float64(w.N) - x) / float64(n)
+	return Welford{N: n, Mean: mean, M2: math.Max(0, w.M2-(x-mean)*(x-w.Mean))}
+}
@@ -0,0 +1,57 @@
+package parse
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWelfordMatchesBruteForce(t *testing.T) {
+	// Large values with a small spread are where summing squares loses
+	// precision.
+	series := []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16, 1e9 + 1, 1e9 + 9}
+
+	var sum float64
+	for _, x := range series {
+		sum += x
+	}
+	mean := sum / float64(len(series))
+	var ss float64
+	for _, x := range series {
+		ss += (x - mean) * (x - mean)
+	}
+	stddev := math.Sqrt(ss / float64(len(series)-1))
+
+	var w Welford
+	for _, x := range series {
+		w.Add(x)
+	}
+	if math.Abs(w.Mean-mean) > 1e-6 || math.Abs(w.StdDev()-stddev) > 1e-9*stddev+1e-6 {
+		t.Errorf("Welford mean %v, stddev %v; brute force %v, %v", w.Mean, w.StdDev(), mean, stddev)
+	}
+	if cv := stddev / mean; math.Abs(w.CV()-cv) > 1e-12 {
+		t.Errorf("CV = %v, want %v", w.CV(), cv)
+	}
+
+	before := w.Without(series[len(series)-1])
+	var rest Welford
+	for _, x := range series[:len(series)-1] {
+		rest.Add(x)
+	}
+	if before.N != rest.N || math.Abs(before.Mean-rest.Mean) > 1e-6 || math.Abs(before.StdDev()-rest.StdDev()) > 1e-6 {
+		t.Errorf("Without = %+v, want %+v", before, rest)
+	}
+}
+
+func TestWelfordDegenerate(t *testing.T) {
+	var w Welford
+	w.Add(5)
+	if w.StdDev() != 0 || !math.IsNaN(w.ZScore(5)) {
+		t.Errorf("one value: stddev %v, z-score %v; want 0 and NaN", w.StdDev(), w.ZScore(5))
+	}
+	var zero Welford
+	zero.Add(0)
+	zero.Add(0)
+	if !math.IsNaN(zero.CV()) {
+		t.Errorf("zero mean CV = %v, want NaN", zero.CV())
+	}
+}
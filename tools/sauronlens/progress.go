@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progressInterval is how often a progressReader redraws its status line.
+const progressInterval = 200 * time.Millisecond
+
+// progressReader counts the bytes and lines read through it and
+// periodically rewrites a status line on w: a percentage when the total
+// size is known, otherwise the bytes and lines read so far.
+type progressReader struct {
+	r     io.Reader
+	w     io.Writer
+	label string
+	total int64 // size of the input in bytes; 0 if unknown
+	bytes int64
+	lines int64
+	last  time.Time
+	now   func() time.Time
+}
+
+func newProgressReader(r io.Reader, w io.Writer, label string, total int64) *progressReader {
+	return &progressReader{r: r, w: w, label: label, total: total, now: time.Now}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.bytes += int64(n)
+	p.lines += int64(bytes.Count(b[:n], []byte{'\n'}))
+	if now := p.now(); err != nil || now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.print()
+	}
+	return n, err
+}
+
+// print writes the current status over the previous one.
+func (p *progressReader) print() {
+	if p.total > 0 {
+		_, _ = fmt.Fprintf(p.w, "\r%s: %3.0f%% (%s of %s)", p.label,
+			float64(p.bytes)/float64(p.total)*100, formatBytes(p.bytes), formatBytes(p.total))
+		return
+	}
+	_, _ = fmt.Fprintf(p.w, "\r%s: %s, %d lines", p.label, formatBytes(p.bytes), p.lines)
+}
+
+// done ends the status line so later output starts on a fresh line.
+func (p *progressReader) done() {
+	p.print()
+	_, _ = fmt.Fprintln(p.w)
+}
+
+// formatBytes renders n bytes in MB with one decimal, like the log's sizes.
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressReader(t *testing.T) {
+	data := strings.Repeat(strings.Repeat("x", 1023)+"\n", 1024) // 1 MB in 1024 lines
+	for _, tt := range []struct {
+		total int64
+		want  string
+	}{
+		{int64(len(data)), "\rlog: 100% (1.0 MB of 1.0 MB)"},
+		{0, "\rlog: 1.0 MB, 1024 lines"},
+	} {
+		var status bytes.Buffer
+		p := newProgressReader(strings.NewReader(data), &status, "log", tt.total)
+		clock := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+		p.now = func() time.Time {
+			clock = clock.Add(time.Millisecond)
+			return clock
+		}
+		n, err := io.Copy(io.Discard, p)
+		if err != nil || n != int64(len(data)) {
+			t.Fatalf("copied %d bytes, %v; want %d", n, err, len(data))
+		}
+		if p.bytes != int64(len(data)) || p.lines != 1024 {
+			t.Errorf("counted %d bytes, %d lines; want %d and 1024", p.bytes, p.lines, len(data))
+		}
+		updates := strings.Split(status.String(), "\r")
+		if got := "\r" + updates[len(updates)-1]; got != tt.want {
+			t.Errorf("total %d: final status %q, want %q", tt.total, got, tt.want)
+		}
+		// The reads of 1 MB, 1ms apart, fit in one progressInterval, so only
+		// the first read and EOF redraw.
+		if len(updates)-1 != 2 {
+			t.Errorf("total %d: %d status updates, want 2", tt.total, len(updates)-1)
+		}
+	}
+}
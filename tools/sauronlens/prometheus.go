@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// promMetric is one gauge of the Prometheus exposition.
+type promMetric struct {
+	name  string
+	help  string
+	value func(parse.ProcessStats) float64
+}
+
+// promMetrics are the gauges written per process, in output order.
+var promMetrics = []promMetric{
+	{"sauron_process_cpu_percent", "Latest CPU usage of the process in percent.", func(s parse.ProcessStats) float64 { return s.LatestCPU }},
+	{"sauron_process_rss_mb", "Latest resident set size of the process in MB.", func(s parse.ProcessStats) float64 { return s.LatestMemory }},
+	{"sauron_process_pss_mb", "Latest proportional set size of the process in MB.", func(s parse.ProcessStats) float64 { return s.LatestPSS }},
+}
+
+// printStatsPrometheus writes the latest CPU, RSS and PSS of every process
+// in the Prometheus text exposition format, one gauge family per metric and
+// one sample per process labelled with its name.
+func printStatsPrometheus(w io.Writer, stats map[string]parse.ProcessStats) error {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, m := range promMetrics {
+		name := promName(m.name)
+		_, _ = fmt.Fprintf(bw, "# HELP %s %s\n", name, m.help)
+		_, _ = fmt.Fprintf(bw, "# TYPE %s gauge\n", name)
+		for _, process := range names {
+			_, _ = fmt.Fprintf(bw, "%s{name=\"%s\"} %s\n", name, promLabelValue(process),
+				strconv.FormatFloat(m.value(stats[process]), 'f', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+// promName replaces every character that is not valid in a Prometheus
+// metric or label name with an underscore.
+func promName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// promLabelValue escapes a label value for the text exposition format.
+var promLabelValue = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
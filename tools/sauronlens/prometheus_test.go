@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestPrintStatsPrometheus(t *testing.T) {
+	stats := map[string]parse.ProcessStats{
+		"mdnsd":      {LatestCPU: 0, LatestMemory: 4.54, LatestPSS: 2},
+		`web "main"`: {LatestCPU: 1.5, LatestMemory: 123.4, LatestPSS: 100.25},
+	}
+	var buf bytes.Buffer
+	if err := printStatsPrometheus(&buf, stats); err != nil {
+		t.Fatal(err)
+	}
+	const want = `# HELP sauron_process_cpu_percent Latest CPU usage of the process in percent.
+# TYPE sauron_process_cpu_percent gauge
+sauron_process_cpu_percent{name="mdnsd"} 0
+sauron_process_cpu_percent{name="web \"main\""} 1.5
+# HELP sauron_process_rss_mb Latest resident set size of the process in MB.
+# TYPE sauron_process_rss_mb gauge
+sauron_process_rss_mb{name="mdnsd"} 4.54
+sauron_process_rss_mb{name="web \"main\""} 123.4
+# HELP sauron_process_pss_mb Latest proportional set size of the process in MB.
+# TYPE sauron_process_pss_mb gauge
+sauron_process_pss_mb{name="mdnsd"} 2
+sauron_process_pss_mb{name="web \"main\""} 100.25
+`
+	if got := buf.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPromName(t *testing.T) {
+	for in, want := range map[string]string{
+		"sauron_process_rss_mb": "sauron_process_rss_mb",
+		"rss-mb.latest":         "rss_mb_latest",
+		"9lives":                "_lives",
+		"cpu:p95":               "cpu:p95",
+	} {
+		if got := promName(in); got != want {
+			t.Errorf("promName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
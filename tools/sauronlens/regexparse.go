@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// requiredRegexGroups are the named groups a --regex pattern must capture.
+var requiredRegexGroups = []string{"name", "cpu", "rss", "timestamp"}
+
+// optionalRegexGroups may be captured by a --regex pattern; missing ones
+// leave the corresponding LogEntry field at its zero value.
+var optionalRegexGroups = []string{"pid", "state", "threads", "vsz", "pss", "uptime", "host"}
+
+// regexParser parses log lines with a user-supplied regular expression whose
+// named groups map to LogEntry fields.
+type regexParser struct {
+	re     *regexp.Regexp
+	groups map[string]int // group name -> submatch index
+	times  *parse.TimeParser
+}
+
+// newRegexParser compiles pattern and checks that it captures every required
+// named group and no unknown ones.
+func newRegexParser(pattern, timeLayout string) (*regexParser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, g := range append(requiredRegexGroups, optionalRegexGroups...) {
+		known[g] = true
+	}
+	groups := make(map[string]int)
+	for i, g := range re.SubexpNames() {
+		if g == "" {
+			continue
+		}
+		if !known[g] {
+			all := append(append([]string(nil), requiredRegexGroups...), optionalRegexGroups...)
+			sort.Strings(all)
+			return nil, fmt.Errorf("unknown named group %q (known: %s)", g, strings.Join(all, ", "))
+		}
+		groups[g] = i
+	}
+	var missing []string
+	for _, g := range requiredRegexGroups {
+		if _, ok := groups[g]; !ok {
+			missing = append(missing, g)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required named groups: %s", strings.Join(missing, ", "))
+	}
+	return &regexParser{re: re, groups: groups, times: &parse.TimeParser{Layout: timeLayout}}, nil
+}
+
+// parse parses a single log line into a LogEntry struct.
+func (p *regexParser) parse(line string) (*parse.LogEntry, error) {
+	m := p.re.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("line does not match --regex")
+	}
+	group := func(name string) string {
+		if i, ok := p.groups[name]; ok {
+			return strings.TrimSpace(m[i])
+		}
+		return ""
+	}
+	number := func(name string) (float64, error) {
+		if _, ok := p.groups[name]; !ok {
+			return 0, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(group(name), "%"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value: %v", name, err)
+		}
+		return f, nil
+	}
+
+	entry := &parse.LogEntry{Name: group("name"), State: group("state"), Host: group("host")}
+	if entry.Name == "" {
+		return nil, fmt.Errorf("empty process name")
+	}
+	entry.PID, _ = strconv.Atoi(group("pid"))
+	entry.Threads = -1
+	if n, err := strconv.Atoi(group("threads")); err == nil && n >= 0 {
+		entry.Threads = n
+	}
+	entry.Uptime = -1
+	if v, err := strconv.ParseFloat(group("uptime"), 64); err == nil && v >= 0 {
+		entry.Uptime = v
+	}
+
+	var err error
+	if entry.CPU, err = number("cpu"); err != nil {
+		return nil, err
+	}
+	if entry.Memory, err = number("rss"); err != nil {
+		return nil, err
+	}
+	if entry.PSS, err = number("pss"); err != nil {
+		return nil, err
+	}
+	if entry.VSZ, err = number("vsz"); err != nil {
+		return nil, err
+	}
+	if entry.Timestamp, err = p.times.Parse(group("timestamp")); err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %v", err)
+	}
+	return entry, nil
+}
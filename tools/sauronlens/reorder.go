@@ -0,0 +1,81 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// reorderBuffer holds back parsed entries for a sliding window of time so
+// lines written slightly out of order by a multi-threaded collector are
+// aggregated in timestamp order. An entry is released once one at least
+// window newer has been read.
+type reorderBuffer struct {
+	window  time.Duration
+	pending entryHeap
+	newest  time.Time
+	seq     int
+
+	OutOfOrder int // entries older than one read before them
+	TooLate    int // out-of-order entries older than the window, aggregated out of order
+}
+
+// push adds entry to the buffer and returns the entries it releases, oldest
+// first.
+func (rb *reorderBuffer) push(entry *parse.LogEntry) []*parse.LogEntry {
+	if entry.Timestamp.Before(rb.newest) {
+		rb.OutOfOrder++
+		if entry.Timestamp.Before(rb.newest.Add(-rb.window)) {
+			rb.TooLate++
+		}
+	} else {
+		rb.newest = entry.Timestamp
+	}
+	rb.seq++
+	heap.Push(&rb.pending, bufferedEntry{entry, rb.seq})
+
+	var ready []*parse.LogEntry
+	cutoff := rb.newest.Add(-rb.window)
+	for rb.pending.Len() > 0 && rb.pending[0].entry.Timestamp.Before(cutoff) {
+		ready = append(ready, heap.Pop(&rb.pending).(bufferedEntry).entry)
+	}
+	return ready
+}
+
+// drain returns every buffered entry, oldest first, and resets the window
+// for the next input.
+func (rb *reorderBuffer) drain() []*parse.LogEntry {
+	ready := make([]*parse.LogEntry, 0, rb.pending.Len())
+	for rb.pending.Len() > 0 {
+		ready = append(ready, heap.Pop(&rb.pending).(bufferedEntry).entry)
+	}
+	rb.newest = time.Time{}
+	return ready
+}
+
+// bufferedEntry is an entry awaiting release; seq keeps entries with equal
+// timestamps in read order.
+type bufferedEntry struct {
+	entry *parse.LogEntry
+	seq   int
+}
+
+// entryHeap is a min-heap of buffered entries by timestamp.
+type entryHeap []bufferedEntry
+
+func (h entryHeap) Len() int { return len(h) }
+func (h entryHeap) Less(i, j int) bool {
+	if !h[i].entry.Timestamp.Equal(h[j].entry.Timestamp) {
+		return h[i].entry.Timestamp.Before(h[j].entry.Timestamp)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h entryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *entryHeap) Push(x any)   { *h = append(*h, x.(bufferedEntry)) }
+func (h *entryHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
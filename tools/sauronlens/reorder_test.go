@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReorderMatchesInOrder(t *testing.T) {
+	start := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	var lines []string
+	for i := range 30 {
+		at := start.Add(time.Duration(i) * 10 * time.Second).Format(time.RFC3339)
+		lines = append(lines, logLine(1, "foo", "Running", float64(10+i%7), float64(i%5), at))
+	}
+	// Swap neighbours, leaving every line at most 10s out of order.
+	shuffled := slices.Clone(lines)
+	swaps := 0
+	for i := 0; i+1 < len(shuffled); i += 3 {
+		shuffled[i], shuffled[i+1] = shuffled[i+1], shuffled[i]
+		swaps++
+	}
+
+	opts := testOptions()
+	opts.retainSamples = true
+	opts.ewmaAlpha = 0.5
+	want, _, err := processLogs(strings.NewReader(strings.Join(lines, "\n")), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.reorder = &reorderBuffer{window: 30 * time.Second}
+	got, _, err := processLogs(strings.NewReader(strings.Join(shuffled, "\n")), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reordered stats differ from the in-order stats")
+	}
+	if opts.reorder.OutOfOrder != swaps || opts.reorder.TooLate != 0 {
+		t.Errorf("%d out of order, %d too late; want %d and 0", opts.reorder.OutOfOrder, opts.reorder.TooLate, swaps)
+	}
+
+	// Without the buffer the EWMA follows read order and differs.
+	opts.reorder = nil
+	unsorted, _, err := processLogs(strings.NewReader(strings.Join(shuffled, "\n")), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unsorted["foo"].EwmaMemory == want["foo"].EwmaMemory {
+		t.Error("shuffling did not affect the EWMA; the test input is too weak")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// resampledPoint is one point on a regular time grid. Valid is false when
+// the point falls inside a gap that is too large to interpolate across.
+type resampledPoint struct {
+	Time   time.Time
+	CPU    float64
+	Memory float64
+	PSS    float64
+	Valid  bool
+}
+
+// resample linearly interpolates samples onto a grid of the given step,
+// starting at the earliest sample and ending at or before the latest.
+// Points between two samples further apart than maxGap are left invalid.
+func resample(samples []parse.Sample, step, maxGap time.Duration) []resampledPoint {
+	sorted := sortedByTime(samples)
+	if len(sorted) == 0 || step <= 0 {
+		return nil
+	}
+
+	var points []resampledPoint
+	end := sorted[len(sorted)-1].Time
+	seg := 0
+	for t := sorted[0].Time; !t.After(end); t = t.Add(step) {
+		for seg < len(sorted)-2 && sorted[seg+1].Time.Before(t) {
+			seg++
+		}
+		a := sorted[seg]
+		b := a
+		if seg+1 < len(sorted) {
+			b = sorted[seg+1]
+		}
+
+		p := resampledPoint{Time: t}
+		span := b.Time.Sub(a.Time)
+		switch {
+		case span == 0:
+			p.CPU, p.Memory, p.PSS, p.Valid = a.CPU, a.Memory, a.PSS, true
+		case span <= maxGap:
+			f := float64(t.Sub(a.Time)) / float64(span)
+			p.CPU = lerp(a.CPU, b.CPU, f)
+			p.Memory = lerp(a.Memory, b.Memory, f)
+			p.PSS = lerp(a.PSS, b.PSS, f)
+			p.Valid = true
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+func lerp(a, b, f float64) float64 { return a + (b-a)*f }
+
+// writeResampled writes the resampled series of every process as CSV with
+// the columns name, timestamp, cpu, rss_mb, pss_mb. Points inside gaps have
+// empty metric columns. Metrics have precision decimals.
+func writeResampled(w io.Writer, stats map[string]parse.ProcessStats, step, maxGap time.Duration, precision int, rounding string) error {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"name", "timestamp", "cpu", "rss_mb", "pss_mb"})
+	for _, name := range names {
+		for _, p := range resample(stats[name].Samples, step, maxGap) {
+			row := []string{name, p.Time.Format(time.RFC3339Nano), "", "", ""}
+			if p.Valid {
+				row[2] = formatFloat(p.CPU, precision, rounding)
+				row[3] = formatFloat(p.Memory, precision, rounding)
+				row[4] = formatFloat(p.PSS, precision, rounding)
+			}
+			_ = cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// Supported --percentile-mode values.
+const (
+	percentileExact  = "exact"
+	percentileApprox = "approx"
+)
+
+// percentile returns the p-th percentile (0–100) of samples using linear
+// interpolation between the closest ranks. samples is not modified. An empty
+// slice yields NaN.
+//
+// Exact percentiles need every sample, which costs memory proportional to
+// the log. Feeding percentile a reservoir sample instead (see parse.Options)
+// bounds memory at parse.ReservoirSize samples per process, at the cost of an
+// estimate whose error grows for extreme percentiles such as p99 on very
+// long captures.
+func percentile(samples []float64, p float64) float64 {
+	if len(samples) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+
+	p = math.Max(0, math.Min(100, p))
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	frac := rank - float64(lo)
+	return sorted[lo] + (sorted[hi]-sorted[lo])*frac
+}
+
+// distributionSamples returns the samples distribution metrics such as
+// percentiles are computed from: the reservoir when one is kept, otherwise
+// every retained sample.
+func distributionSamples(stat parse.ProcessStats) []parse.Sample {
+	if stat.Reservoir != nil {
+		return stat.Reservoir
+	}
+	return stat.Samples
+}
+
+func sampleCPU(s parse.Sample) float64 { return s.CPU }
+func sampleRSS(s parse.Sample) float64 { return s.Memory }
+func samplePSS(s parse.Sample) float64 { return s.PSS }
+
+// sampleValues extracts one metric from each sample.
+func sampleValues(samples []parse.Sample, metric func(parse.Sample) float64) []float64 {
+	values := make([]float64, len(samples))
+	for i, s := range samples {
+		values[i] = metric(s)
+	}
+	return values
+}
+
+// cpuSeconds integrates the CPU percentage of samples over time with the
+// trapezoidal rule, giving the approximate CPU-seconds consumed. Intervals
+// longer than maxStep are sampling gaps whose CPU use is unknown, so they
+// are skipped rather than interpolated across; zero counts every interval.
+func cpuSeconds(samples []parse.Sample, maxStep time.Duration) float64 {
+	sorted := sortedByTime(samples)
+	total := 0.0
+	for i := 1; i < len(sorted); i++ {
+		dt := sorted[i].Time.Sub(sorted[i-1].Time)
+		if maxStep > 0 && dt > maxStep {
+			continue
+		}
+		total += (sorted[i-1].CPU + sorted[i].CPU) / 2 / 100 * dt.Seconds()
+	}
+	return total
+}
+
+// sortedByTime returns a copy of samples in chronological order.
+func sortedByTime(samples []parse.Sample) []parse.Sample {
+	sorted := append([]parse.Sample(nil), samples...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	return sorted
+}
+
+// timelineWidth is the maximum number of columns in a state timeline.
+const timelineWidth = 40
+
+// stateSeverity ranks state codes so downsampling keeps the most notable
+// state in each column.
+var stateSeverity = map[byte]int{'S': 1, 'R': 2, 'T': 3, 'D': 4, 'X': 5, 'Z': 6}
+
+// stateTimeline renders the states of samples in chronological order, one
+// letter per sample. When there are more samples than width, each column
+// covers a run of samples and shows its most severe state, so a brief D or Z
+// is never hidden.
+func stateTimeline(samples []parse.Sample, width int) string {
+	sorted := sortedByTime(samples)
+	n := len(sorted)
+	if n <= width {
+		width = n
+	}
+	out := make([]byte, width)
+	for col := 0; col < width; col++ {
+		start, end := col*n/width, (col+1)*n/width
+		best := parse.StateCode(sorted[start].State)
+		for _, s := range sorted[start+1 : end] {
+			if c := parse.StateCode(s.State); stateSeverity[c] > stateSeverity[best] {
+				best = c
+			}
+		}
+		out[col] = best
+	}
+	return string(out)
+}
+
+// sparkBlocks are the bar heights of a sparkline, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders values as a row of block characters scaled between their
+// minimum and maximum. When there are more values than width, each column
+// shows the mean of its run of values.
+func sparkline(values []float64, width int) string {
+	n := len(values)
+	if n == 0 || width <= 0 {
+		return ""
+	}
+	if n <= width {
+		width = n
+	}
+	cols := make([]float64, width)
+	for col := range cols {
+		start, end := col*n/width, (col+1)*n/width
+		sum := 0.0
+		for _, v := range values[start:end] {
+			sum += v
+		}
+		cols[col] = sum / float64(end-start)
+	}
+
+	lo, hi := cols[0], cols[0]
+	for _, v := range cols {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	out := make([]rune, width)
+	for i, v := range cols {
+		level := 0
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+		}
+		out[i] = sparkBlocks[level]
+	}
+	return string(out)
+}
+
+// burstiness returns the fraction of samples whose CPU exceeds the process's
+// own average. Values well below 0.5 indicate short bursts over a low
+// baseline; values well above it indicate a plateau with occasional dips.
+func burstiness(stat parse.ProcessStats) float64 {
+	if len(stat.Samples) == 0 {
+		return 0
+	}
+	avg := stat.TotalCPU / float64(stat.Count)
+	above := 0
+	for _, s := range stat.Samples {
+		if s.CPU > avg {
+			above++
+		}
+	}
+	return float64(above) / float64(len(stat.Samples))
+}
+
+// stabilityBaseline returns the RSS baseline of stat selected by metric:
+// "first" (the earliest sample), "mean", or a literal size such as 512MB.
+func stabilityBaseline(stat parse.ProcessStats, metric string) (float64, error) {
+	switch metric {
+	case "first":
+		sorted := sortedByTime(stat.Samples)
+		if len(sorted) == 0 {
+			return 0, nil
+		}
+		return sorted[0].Memory, nil
+	case "mean":
+		return stat.TotalMemory / float64(stat.Count), nil
+	}
+	return parseSize(metric)
+}
+
+// stability returns the percentage of samples whose RSS lies within
+// ±bandPct percent of baseline.
+func stability(samples []parse.Sample, baseline, bandPct float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	tolerance := math.Abs(baseline) * bandPct / 100
+	within := 0
+	for _, s := range samples {
+		if math.Abs(s.Memory-baseline) <= tolerance {
+			within++
+		}
+	}
+	return float64(within) / float64(len(samples)) * 100
+}
+
+// formatState renders the latest state with a summary of its transitions,
+// e.g. "Running (3 transitions, last from D at 2025-02-21 12:41:52)".
+func formatState(stat parse.ProcessStats) string {
+	if stat.Transitions == 0 {
+		return stat.State
+	}
+	noun := "transitions"
+	if stat.Transitions == 1 {
+		noun = "transition"
+	}
+	abnormal := ""
+	if stat.Abnormal > 0 {
+		abnormal = fmt.Sprintf(", %d zombie/stopped", stat.Abnormal)
+	}
+	return fmt.Sprintf("%s (%d %s%s, last from %c at %s)", stat.State, stat.Transitions, noun, abnormal,
+		parse.StateCode(stat.PrevState), stat.LastChange.Format("2006-01-02 15:04:05"))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestPercentile(t *testing.T) {
+	samples := []float64{15, 20, 35, 40, 50}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 15},
+		{25, 20},
+		{40, 29},
+		{50, 35},
+		{95, 48},
+		{100, 50},
+		{150, 50},
+	}
+	for _, tt := range tests {
+		if got := percentile(samples, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentile(%v, %v) = %v, want %v", samples, tt.p, got, tt.want)
+		}
+	}
+	if got := percentile([]float64{7}, 99); got != 7 {
+		t.Errorf("percentile of one sample = %v, want 7", got)
+	}
+	if got := percentile(nil, 50); !math.IsNaN(got) {
+		t.Errorf("percentile(nil) = %v, want NaN", got)
+	}
+	unsorted := []float64{3, 1, 2}
+	percentile(unsorted, 50)
+	if unsorted[0] != 3 || unsorted[1] != 1 || unsorted[2] != 2 {
+		t.Errorf("percentile modified its input: %v", unsorted)
+	}
+}
+
+func TestSparklineAscending(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	if got, want := sparkline(values, 40), "▁▂▃▄▅▆▇█"; got != want {
+		t.Errorf("sparkline(%v) = %q, want %q", values, got, want)
+	}
+
+	// Downsampled to fewer columns, the heights still never drop.
+	var long []float64
+	for i := range 100 {
+		long = append(long, float64(i))
+	}
+	got := []rune(sparkline(long, 10))
+	if len(got) != 10 || got[0] != '▁' || got[9] != '█' {
+		t.Fatalf("sparkline of 100 ascending values in 10 columns = %q", string(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] < got[i-1] {
+			t.Errorf("column %d is lower than column %d: %q", i, i-1, string(got))
+		}
+	}
+
+	if got := sparkline([]float64{3, 3, 3}, 40); got != "▁▁▁" {
+		t.Errorf("flat sparkline = %q", got)
+	}
+}
+
+func TestCPUSeconds(t *testing.T) {
+	start := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	// 50% every 10s for 10 minutes, then after a 1h gap another 10s.
+	var samples []parse.Sample
+	for i := 0; i <= 60; i++ {
+		samples = append(samples, parse.Sample{Time: start.Add(time.Duration(i) * 10 * time.Second), CPU: 50})
+	}
+	end := samples[len(samples)-1].Time
+	samples = append(samples,
+		parse.Sample{Time: end.Add(time.Hour), CPU: 50},
+		parse.Sample{Time: end.Add(time.Hour + 10*time.Second), CPU: 50},
+	)
+
+	// Half a core for 600s with the gap skipped, plus 10s after it.
+	if got := cpuSeconds(samples, time.Minute); math.Abs(got-305) > 1e-9 {
+		t.Errorf("cpuSeconds with gaps skipped = %v, want 305", got)
+	}
+	if got := cpuSeconds(samples, 0); math.Abs(got-(305+1800)) > 1e-9 {
+		t.Errorf("cpuSeconds counting the gap = %v, want 2105", got)
+	}
+	if got := cpuSeconds(samples[:1], 0); got != 0 {
+		t.Errorf("cpuSeconds of one sample = %v", got)
+	}
+}
+
+func TestStabilityLineUsesUnit(t *testing.T) {
+	opts := testOptions()
+	opts.retainSamples = true
+	opts.stabilityBand = 10
+	opts.baselineMetric = "first"
+	opts.unit = unitGB
+	opts.precision = 1
+	stats := aggregate(t, opts,
+		logLine(1, "foo", "Running", 1024, 1, "2025-02-21T12:00:00Z"),
+		logLine(1, "foo", "Running", 1100, 1, "2025-02-21T12:01:00Z"),
+		logLine(1, "foo", "Running", 2048, 1, "2025-02-21T12:02:00Z"),
+	)
+	if got, want := reportValue(textReport(t, stats, opts), "RSS Stability:"), "66.7% within ±10% of first (1.0 GB)"; got != want {
+		t.Errorf("RSS Stability = %q, want %q", got, want)
+	}
+}
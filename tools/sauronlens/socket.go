@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// serveUnix accepts connections on a Unix domain socket at path, one at a
+// time, and merges the log lines of every connection into a single stats
+// map. It returns once the process receives SIGINT or SIGTERM.
+func serveUnix(path string, opts options) (map[string]parse.ProcessStats, error) {
+	return serve("unix", path, opts, true)
+}
+
+// serveURL is serveUnix for a --listen address such as tcp://:9000 or
+// unix:///run/sauron.sock. Unless keepListening is set it returns after the
+// first connection closes.
+func serveURL(url string, opts options, keepListening bool) (map[string]parse.ProcessStats, error) {
+	network, address, ok := strings.Cut(url, "://")
+	if !ok || (network != "tcp" && network != "unix") || address == "" {
+		return nil, fmt.Errorf("expected tcp://host:port or unix:///path, got %q", url)
+	}
+	return serve(network, address, opts, keepListening)
+}
+
+// serve listens on address and aggregates the connections it accepts.
+func serve(network, address string, opts options, keepListening bool) (map[string]parse.ProcessStats, error) {
+	stats, err := acceptLogs(network, address, opts, keepListening)
+	if err != nil {
+		return nil, err
+	}
+	finalizeStats(stats)
+	return stats, nil
+}
+
+// listen opens the listener for serve. For Unix sockets a stale socket left
+// behind by a previous run is removed first, but never anything that is not
+// a socket.
+func listen(network, address string) (net.Listener, error) {
+	if network == "unix" {
+		if fi, err := os.Lstat(address); err == nil {
+			if fi.Mode()&os.ModeSocket == 0 {
+				return nil, fmt.Errorf("%s exists and is not a socket", address)
+			}
+			if err := os.Remove(address); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// acceptLogs runs the accept loop of serve.
+func acceptLogs(network, address string, opts options, keepListening bool) (map[string]parse.ProcessStats, error) {
+	ln, err := listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close() //nolint:errcheck
+
+	var (
+		mu      sync.Mutex
+		active  net.Conn
+		stopped bool
+	)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		<-sig
+		mu.Lock()
+		stopped = true
+		if active != nil {
+			_ = active.Close()
+		}
+		mu.Unlock()
+		_ = ln.Close()
+	}()
+
+	stats := make(map[string]parse.ProcessStats)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return stats, nil
+			}
+			return nil, err
+		}
+
+		mu.Lock()
+		if stopped {
+			mu.Unlock()
+			_ = conn.Close()
+			return stats, nil
+		}
+		active = conn
+		mu.Unlock()
+
+		err = aggregateLogs(stats, conn, opts)
+
+		mu.Lock()
+		active = nil
+		wasStopped := stopped
+		mu.Unlock()
+		_ = conn.Close()
+
+		// A read error caused by the interrupt closing the connection is
+		// expected; anything else is reported but does not stop the listener.
+		if wasStopped {
+			return stats, nil
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading connection:", err)
+		}
+		if !keepListening {
+			return stats, nil
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// frame returns line as a length-prefixed --input-format framed record.
+func frame(line string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(line))), line...)
+}
+
+func TestAggregateLogsFromPipe(t *testing.T) {
+	first := logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z")
+	second := logLine(1, "foo", "Running", 20, 1, "2025-02-21T12:01:00Z")
+	for _, tt := range []struct {
+		format string
+		data   []byte
+	}{
+		{inputLines, []byte(first + "\n" + second + "\n")},
+		// Framed records may carry a newline without being split on it.
+		{inputFramed, append(frame(first), frame(second+"\n")...)},
+	} {
+		client, server := net.Pipe()
+		go func() {
+			// net.Pipe is unbuffered, so write a byte at a time to
+			// exercise reads split mid-line and mid-header.
+			for i := range tt.data {
+				if _, err := client.Write(tt.data[i : i+1]); err != nil {
+					return
+				}
+			}
+			_ = client.Close()
+		}()
+		opts := testOptions()
+		opts.inputFormat = tt.format
+		stats := map[string]parse.ProcessStats{}
+		if err := aggregateLogs(stats, server, opts); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if foo := stats["foo"]; foo.Count != 2 || foo.MaxMemory != 20 {
+			t.Errorf("%s: foo count %d, max RSS %v; want 2 and 20", tt.format, foo.Count, foo.MaxMemory)
+		}
+	}
+}
+
+func TestServeURLUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sauron.sock")
+	type result struct {
+		stats map[string]parse.ProcessStats
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stats, err := serveURL("unix://"+path, testOptions(), false)
+		done <- result{stats, err}
+	}()
+
+	var conn net.Conn
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		var err error
+		if conn, err = net.Dial("unix", path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dialing %s: %v", path, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := conn.Write([]byte(logLine(1, "bar", "Running", 5, 1, "2025-02-21T12:00:00Z") + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if bar := r.stats["bar"]; bar.Count != 1 || bar.LatestMemory != 5 {
+			t.Errorf("bar = count %d, RSS %v; want 1 and 5", bar.Count, bar.LatestMemory)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveURL did not return after the connection closed")
+	}
+}
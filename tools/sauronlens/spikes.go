@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// spike is a run of consecutive samples with CPU above a threshold.
+type spike struct {
+	Start, End time.Time // first and last sample of the run
+	Peak       float64   // highest CPU in the run
+}
+
+// cpuSpikes returns the runs of samples whose CPU stays above threshold for
+// at least minDuration, measured from the first to the last sample of the
+// run. Any sample at or below threshold ends a run.
+func cpuSpikes(samples []parse.Sample, threshold float64, minDuration time.Duration) []spike {
+	var spikes []spike
+	var run *spike
+	flush := func() {
+		if run != nil && run.End.Sub(run.Start) >= minDuration {
+			spikes = append(spikes, *run)
+		}
+		run = nil
+	}
+	for _, s := range sortedByTime(samples) {
+		if s.CPU <= threshold {
+			flush()
+			continue
+		}
+		if run == nil {
+			run = &spike{Start: s.Time, Peak: s.CPU}
+		}
+		run.End = s.Time
+		run.Peak = max(run.Peak, s.CPU)
+	}
+	flush()
+	return spikes
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestCPUSpikes(t *testing.T) {
+	start := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	at := func(minute int) time.Time { return start.Add(time.Duration(minute) * time.Minute) }
+	// One sample a minute: a lone spike at 1, a 3-minute spike at 3–6, one
+	// dip at 7, a 2-minute spike at 8–10 and a 1-minute blip at 12–13.
+	cpu := []float64{10, 95, 10, 85, 90, 99, 88, 40, 91, 92, 93, 20, 96, 97, 5}
+	var samples []parse.Sample
+	for i, v := range cpu {
+		samples = append(samples, parse.Sample{Time: at(i), CPU: v})
+	}
+	samples[0], samples[5] = samples[5], samples[0] // out of order
+
+	want := []spike{
+		{Start: at(3), End: at(6), Peak: 99},
+		{Start: at(8), End: at(10), Peak: 93},
+	}
+	if got := cpuSpikes(samples, 80, 2*time.Minute); !reflect.DeepEqual(got, want) {
+		t.Errorf("cpuSpikes =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := cpuSpikes(samples, 99, 0); len(got) != 0 {
+		t.Errorf("threshold is exclusive, got %+v", got)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+
+	_ "modernc.org/sqlite" // pure-Go driver, so the build needs no cgo
+)
+
+// sqliteBatchSize is the number of rows inserted per transaction.
+const sqliteBatchSize = 10000
+
+// sqliteSchema creates the table --sqlite archives entries into. Timestamps
+// are stored as RFC 3339 text in UTC, which sorts and compares correctly.
+// Rows are keyed by host and name, as the stats are, so logs from several
+// hosts stay apart.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS entries (
+	pid       INTEGER,
+	host      TEXT,
+	name      TEXT NOT NULL,
+	state     TEXT,
+	cpu       REAL,
+	rss       REAL,
+	pss       REAL,
+	vsz       REAL,
+	threads   INTEGER,
+	uptime    REAL,
+	timestamp TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS entries_name_timestamp ON entries (name, timestamp);`
+
+// sqliteHostIndex indexes entries by its key; it is created after
+// addHostColumn, so databases from before the host column get it too.
+const sqliteHostIndex = `CREATE INDEX IF NOT EXISTS entries_host_name_timestamp ON entries (host, name, timestamp);`
+
+// sqliteExport appends parsed entries to a SQLite database, committing
+// every sqliteBatchSize rows.
+type sqliteExport struct {
+	db     *sql.DB
+	tx     *sql.Tx
+	insert *sql.Stmt
+	rows   int
+}
+
+// openSQLite opens or creates the database at path and its entries table.
+func openSQLite(path string) (*sqliteExport, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range []func(*sql.DB) error{
+		func(db *sql.DB) error { _, err := db.Exec(sqliteSchema); return err },
+		addHostColumn,
+		func(db *sql.DB) error { _, err := db.Exec(sqliteHostIndex); return err },
+	} {
+		if err := step(db); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	return &sqliteExport{db: db}, nil
+}
+
+// addHostColumn adds the host column to an entries table created before
+// it existed.
+func addHostColumn(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('entries') WHERE name = 'host'`)
+	if err != nil {
+		return err
+	}
+	exists := rows.Next()
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	_, err = db.Exec(`ALTER TABLE entries ADD COLUMN host TEXT`)
+	return err
+}
+
+// add queues entry for insertion. Unknown threads, uptime and host are
+// stored as NULL.
+func (e *sqliteExport) add(entry *parse.LogEntry) error {
+	if e.tx == nil {
+		tx, err := e.db.Begin()
+		if err != nil {
+			return err
+		}
+		insert, err := tx.Prepare(`INSERT INTO entries
+			(pid, host, name, state, cpu, rss, pss, vsz, threads, uptime, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		e.tx, e.insert = tx, insert
+	}
+	var host, threads, uptime any
+	if entry.Host != "" {
+		host = entry.Host
+	}
+	if entry.Threads >= 0 {
+		threads = entry.Threads
+	}
+	if entry.Uptime >= 0 {
+		uptime = entry.Uptime
+	}
+	_, err := e.insert.Exec(entry.PID, host, entry.Name, entry.State, entry.CPU, entry.Memory, entry.PSS, entry.VSZ,
+		threads, uptime, entry.Timestamp.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("sqlite: %w", err)
+	}
+	if e.rows++; e.rows%sqliteBatchSize == 0 {
+		return e.commit()
+	}
+	return nil
+}
+
+// commit commits the pending batch, if any.
+func (e *sqliteExport) commit() error {
+	if e.tx == nil {
+		return nil
+	}
+	_ = e.insert.Close()
+	err := e.tx.Commit()
+	e.tx, e.insert = nil, nil
+	return err
+}
+
+// Close commits the pending batch and closes the database.
+func (e *sqliteExport) Close() error {
+	err := e.commit()
+	if cerr := e.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// closeSQLite closes the --sqlite database, if any, reporting a failure to
+// commit the last batch. It returns false on error.
+func closeSQLite(opts options) bool {
+	if opts.sqlite == nil {
+		return true
+	}
+	if err := opts.sqlite.Close(); err != nil {
+		fmt.Println("Error writing SQLite database:", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.db")
+	export, err := openSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions()
+	opts.sqlite = export
+	aggregate(t, opts,
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		"Host: web-1 | "+logLine(1, "foo", "Running", 42.5, 3, "2025-02-21T14:01:00+02:00"),
+		logLine(2, "bar", "Running", 5, 0, "2025-02-21T12:00:00Z"),
+	)
+	if err := export.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+	var rows int
+	if err := db.QueryRow(`SELECT count(*) FROM entries`).Scan(&rows); err != nil || rows != 3 {
+		t.Fatalf("row count = %d, %v; want 3", rows, err)
+	}
+	var rss float64
+	var host, timestamp string
+	err = db.QueryRow(`SELECT rss, host, timestamp FROM entries WHERE name = 'foo' AND host IS NOT NULL`).Scan(&rss, &host, &timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rss != 42.5 || host != "web-1" || timestamp != "2025-02-21T12:01:00Z" {
+		t.Errorf("web-1 foo row = rss %v, host %q, timestamp %q", rss, host, timestamp)
+	}
+}
+
+func TestSQLiteAddsHostColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE entries (pid INTEGER, name TEXT NOT NULL, state TEXT, cpu REAL, rss REAL, pss REAL,
+		vsz REAL, threads INTEGER, uptime REAL, timestamp TEXT NOT NULL)`)
+	_ = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := openSQLite(path)
+	if err != nil {
+		t.Fatalf("opening a database without the host column: %v", err)
+	}
+	opts := testOptions()
+	opts.sqlite = export
+	opts.host = "cam-1"
+	aggregate(t, opts, logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"))
+	if err := export.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+	var host string
+	if err := db.QueryRow(`SELECT host FROM entries`).Scan(&host); err != nil || host != "cam-1" {
+		t.Errorf("migrated host = %q, %v; want cam-1", host, err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// stateUnknown buckets processes whose latest state has no /proc code.
+const stateUnknown = "unknown"
+
+// stateOrder is the order states are listed in a --by-state summary.
+var stateOrder = []string{"R", "S", "D", "T", "Z", "X", stateUnknown}
+
+// stateNames describes each state code for the summary.
+var stateNames = map[string]string{
+	"R": "running",
+	"S": "sleeping",
+	"D": "uninterruptible sleep",
+	"T": "stopped",
+	"Z": "zombie",
+	"X": "dead",
+}
+
+// stateBucket totals the processes sharing a latest state.
+type stateBucket struct {
+	Count    int
+	TotalRSS float64 // sum of the latest RSS in MB
+}
+
+// summarizeStates buckets processes by the /proc code of their latest state.
+func summarizeStates(stats map[string]parse.ProcessStats) map[string]stateBucket {
+	buckets := make(map[string]stateBucket)
+	for _, stat := range stats {
+		key := string(parse.StateCode(stat.State))
+		if _, known := stateNames[key]; !known {
+			key = stateUnknown
+		}
+		b := buckets[key]
+		b.Count++
+		b.TotalRSS += stat.LatestMemory
+		buckets[key] = b
+	}
+	return buckets
+}
+
+// printStateSummary prints the process count and total latest RSS per state.
+func printStateSummary(w io.Writer, stats map[string]parse.ProcessStats) {
+	buckets := summarizeStates(stats)
+	_, _ = fmt.Fprintln(w, "Processes by state:")
+	for _, state := range stateOrder {
+		b, ok := buckets[state]
+		if !ok {
+			continue
+		}
+		label := state
+		if name, ok := stateNames[state]; ok {
+			label += " (" + name + ")"
+		}
+		noun := "processes"
+		if b.Count == 1 {
+			noun = "process"
+		}
+		_, _ = fmt.Fprintf(w, "  %-27s %3d %-9s %.2f MB RSS\n", label+":", b.Count, noun+",", b.TotalRSS)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestSummarizeStates(t *testing.T) {
+	stats := map[string]parse.ProcessStats{
+		"a": {State: "Running", LatestMemory: 10},
+		"b": {State: "Sleeping (interruptible)", LatestMemory: 2.5},
+		"c": {State: "S", LatestMemory: 1.5},
+		"d": {State: "Zombie", LatestMemory: 0},
+		"e": {State: "Z", LatestMemory: 0.25},
+		"f": {State: "Zombie", LatestMemory: 0},
+		"g": {State: "Sleeping (uninterruptible)", LatestMemory: 7},
+		"h": {State: "", LatestMemory: 3},
+		"i": {State: "Q", LatestMemory: 4},
+	}
+	want := map[string]stateBucket{
+		"R":          {Count: 1, TotalRSS: 10},
+		"S":          {Count: 2, TotalRSS: 4},
+		"Z":          {Count: 3, TotalRSS: 0.25},
+		"D":          {Count: 1, TotalRSS: 7},
+		stateUnknown: {Count: 2, TotalRSS: 7},
+	}
+	if got := summarizeStates(stats); !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeStates =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// Summary holds totals across every process in a report.
+type Summary struct {
+	Processes int
+	TotalRSS  float64 // sum of the latest RSS in MB
+	TotalPSS  float64 // sum of the latest PSS in MB; counts shared pages once
+	TopCPU    string  // process with the highest average CPU; "" if none
+	TopAvgCPU float64
+	Sanitized int // negative values clamped to 0 while reading the input
+}
+
+// summarize totals stats. Ties for the top CPU process go to the name that
+// sorts first.
+func summarize(stats map[string]parse.ProcessStats) Summary {
+	var s Summary
+	for name, stat := range stats {
+		s.Processes++
+		s.TotalRSS += stat.LatestMemory
+		s.TotalPSS += stat.LatestPSS
+		avg := stat.TotalCPU / float64(stat.Count)
+		if s.TopCPU == "" || avg > s.TopAvgCPU || (avg == s.TopAvgCPU && name < s.TopCPU) {
+			s.TopCPU, s.TopAvgCPU = name, avg
+		}
+	}
+	return s
+}
+
+// printSummary writes s as the footer of the text report.
+func printSummary(out io.Writer, s Summary, unit string, precision int, rounding string) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Summary:")
+	_, _ = fmt.Fprintf(w, "  %-22s\t%d\n", "Processes:", s.Processes)
+	_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Total Latest RSS:", formatMem(s.TotalRSS, unit, precision, rounding))
+	_, _ = fmt.Fprintf(w, "  %-22s\t%s\n", "Total Latest PSS:", formatMem(s.TotalPSS, unit, precision, rounding))
+	if s.TopCPU != "" {
+		_, _ = fmt.Fprintf(w, "  %-22s\t%s (%s avg)\n", "Top CPU:", s.TopCPU, formatPercent(s.TopAvgCPU, precision, rounding))
+	}
+	if s.Sanitized > 0 {
+		_, _ = fmt.Fprintf(w, "  %-22s\t%d (clamped to 0)\n", "Negative Values:", s.Sanitized)
+	}
+	_ = w.Flush()
+}
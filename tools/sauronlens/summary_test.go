@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// summaryFixture has three processes, httpd averaging the most CPU.
+var summaryFixture = map[string]parse.ProcessStats{
+	"httpd": {Count: 2, TotalCPU: 30, LatestMemory: 100, LatestPSS: 60},
+	"mdnsd": {Count: 4, TotalCPU: 8, LatestMemory: 4.5, LatestPSS: 2},
+	"sshd":  {Count: 1, TotalCPU: 12, LatestMemory: 20.25, LatestPSS: 10},
+}
+
+func TestSummarize(t *testing.T) {
+	want := Summary{Processes: 3, TotalRSS: 124.75, TotalPSS: 72, TopCPU: "httpd", TopAvgCPU: 15}
+	if got := summarize(summaryFixture); got != want {
+		t.Errorf("summarize = %+v, want %+v", got, want)
+	}
+}
+
+func TestReportSummaryCoversProcessesLeftOutByTop(t *testing.T) {
+	var buf bytes.Buffer
+	opts := testOptions()
+	opts.out = &buf
+	opts.top = 1
+	opts.sortBy = sortRSS
+	report(summaryFixture, opts)
+	out := buf.String()
+	if !strings.Contains(out, "Process httpd:") || strings.Contains(out, "Process sshd:") {
+		t.Errorf("--top 1 --sort rss did not report only httpd:\n%s", out)
+	}
+	if got := reportValue(out, "Processes:"); got != "3" {
+		t.Errorf("--top 1 summary Processes = %q, want 3", got)
+	}
+	if got := reportValue(out, "Total Latest RSS:"); got != "124.75 MB" {
+		t.Errorf("--top 1 summary Total Latest RSS = %q, want 124.75 MB", got)
+	}
+	if got := reportValue(out, "Top CPU:"); got != "httpd (15.00% avg)" {
+		t.Errorf("--top 1 summary Top CPU = %q", got)
+	}
+}
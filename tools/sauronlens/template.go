@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// templateProcess is what a --template is executed with: a process's stats
+// plus the key it is reported under.
+type templateProcess struct {
+	Key string
+	parse.ProcessStats
+}
+
+// newReportTemplate parses a --template. Besides the text/template builtins
+// it provides avgCPU, avgRSS, avgPSS and window, which take the process (.),
+// and num, which formats a number to the report precision:
+//
+//	{{.Key}}: {{num (avgCPU .)}}% CPU over {{window .}}
+func newReportTemplate(text string, precision int, rounding string) (*template.Template, error) {
+	return template.New("report").Funcs(template.FuncMap{
+		"avgCPU": func(p templateProcess) float64 { return p.TotalCPU / float64(p.Count) },
+		"avgRSS": func(p templateProcess) float64 { return p.TotalMemory / float64(p.Count) },
+		"avgPSS": func(p templateProcess) float64 { return p.TotalPSS / float64(p.Count) },
+		"window": func(p templateProcess) time.Duration {
+			return p.LatestTime.Sub(p.FirstTime).Round(time.Second)
+		},
+		"num": func(v float64) string { return formatFloat(v, precision, rounding) },
+	}).Parse(text)
+}
+
+// loadReportTemplate returns the --template text, reading it from path when
+// --template-file was given instead.
+func loadReportTemplate(text, path string) (string, error) {
+	if path == "" {
+		return text, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// printStatsTemplate executes tmpl once per process in sortBy order. Each
+// process ends with a newline unless the template already ends with one.
+func printStatsTemplate(out io.Writer, stats map[string]parse.ProcessStats, sortBy string, tmpl *template.Template) error {
+	for _, name := range sortedNames(stats, sortBy) {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, templateProcess{Key: name, ProcessStats: stats[name]}); err != nil {
+			return fmt.Errorf("process %s: %w", name, err)
+		}
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+		if _, err := io.WriteString(out, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
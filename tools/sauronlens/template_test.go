@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestPrintStatsTemplate(t *testing.T) {
+	start := time.Date(2025, 2, 21, 12, 0, 0, 0, time.UTC)
+	stats := map[string]parse.ProcessStats{
+		"mdnsd": {State: "Sleeping (interruptible)", Count: 2, TotalCPU: 1, TotalMemory: 9, FirstTime: start, LatestTime: start.Add(90 * time.Second)},
+		"httpd": {State: "Running", Count: 4, TotalCPU: 10, TotalMemory: 100, FirstTime: start, LatestTime: start.Add(time.Hour)},
+	}
+	tmpl, err := newReportTemplate(`{{.Key}} [{{.State}}]: {{num (avgCPU .)}}% CPU, {{num (avgRSS .)}} MB over {{window .}}`, 1, roundHalfEven)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := printStatsTemplate(&buf, stats, sortName, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	want := "httpd [Running]: 2.5% CPU, 25.0 MB over 1h0m0s\n" +
+		"mdnsd [Sleeping (interruptible)]: 0.5% CPU, 4.5 MB over 1m30s\n"
+	if got := buf.String(); got != want {
+		t.Errorf("template output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNewReportTemplateParseError(t *testing.T) {
+	if _, err := newReportTemplate(`{{.Key`, 2, roundHalfEven); err == nil {
+		t.Error("unterminated action parsed without error")
+	}
+	if _, err := newReportTemplate(`{{nosuch .}}`, 2, roundHalfEven); err == nil {
+		t.Error("unknown function parsed without error")
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// printTrace writes one line per sample, in chronological order, as
+// HH:MM:SS CPU% RSS PSS STATE, with metrics to precision decimals.
+func printTrace(out io.Writer, samples []parse.Sample, precision int, rounding string) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tCPU%\tRSS\tPSS\tSTATE")
+	for _, s := range sortedByTime(samples) {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Time.Format("15:04:05"),
+			formatFloat(s.CPU, precision, rounding), formatFloat(s.Memory, precision, rounding), formatFloat(s.PSS, precision, rounding), s.State)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// timePoint is a single timestamped value of a metric.
+type timePoint struct {
+	T time.Time
+	V float64
+}
+
+// rssPoints returns the RSS series of the retained samples.
+func rssPoints(samples []parse.Sample) []timePoint {
+	points := make([]timePoint, len(samples))
+	for i, s := range samples {
+		points[i] = timePoint{T: s.Time, V: s.Memory}
+	}
+	return points
+}
+
+// memoryTrend fits a least-squares line to samples and returns its slope in
+// units per hour together with the coefficient of determination R². Fewer
+// than two distinct timestamps yield a zero slope and R².
+func memoryTrend(samples []timePoint) (slopePerHour float64, r2 float64) {
+	if len(samples) < 2 {
+		return 0, 0
+	}
+	// Hours relative to the first sample keep the sums well conditioned.
+	t0 := samples[0].T
+	n := float64(len(samples))
+	var sumX, sumY float64
+	for _, p := range samples {
+		sumX += p.T.Sub(t0).Hours()
+		sumY += p.V
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for _, p := range samples {
+		dx := p.T.Sub(t0).Hours() - meanX
+		dy := p.V - meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+	slopePerHour = sxy / sxx
+	if syy == 0 {
+		// A perfectly flat series is fully explained by the fit.
+		return slopePerHour, 1
+	}
+	return slopePerHour, sxy * sxy / (sxx * syy)
+}
+
+// isLeaking reports whether an RSS trend grows fast enough, and fits well
+// enough, to be flagged as a possible leak.
+func isLeaking(slopePerHour, r2 float64, opts options) bool {
+	return slopePerHour > opts.leakSlope && r2 >= opts.leakR2
+}
+
+// projectMemory describes when a process whose latest RSS is latestMB and
+// which grows at slopePerHour reaches ceilingMB.
+func projectMemory(latestMB, slopePerHour, ceilingMB float64) string {
+	ceiling := formatSize(ceilingMB)
+	switch {
+	case latestMB >= ceilingMB:
+		return fmt.Sprintf("already at or above %s", ceiling)
+	case slopePerHour <= 0:
+		return fmt.Sprintf("not growing (%+.2f MB/h), will not reach %s", slopePerHour, ceiling)
+	}
+	hours := (ceilingMB - latestMB) / slopePerHour
+	eta := time.Duration(hours * float64(time.Hour))
+	return fmt.Sprintf("will reach %s in ~%s at current rate", ceiling, shortDuration(eta))
+}
+
+// formatSize formats a size in MB using the largest whole unit, e.g. 512MB
+// or 16GB.
+func formatSize(mb float64) string {
+	if mb >= 1024 && mb == float64(int64(mb/1024))*1024 {
+		return fmt.Sprintf("%gGB", mb/1024)
+	}
+	return fmt.Sprintf("%gMB", mb)
+}
+
+// shortDuration formats d rounded to the minute without trailing zero units,
+// e.g. 3h12m. Durations under a minute are shown in seconds.
+func shortDuration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := d.Round(time.Minute).String()
+	s = strings.TrimSuffix(s, "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
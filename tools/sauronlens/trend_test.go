@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMemoryTrendFlagsOnlyRisingSeries(t *testing.T) {
+	start := time.Date(2025, 2, 21, 0, 0, 0, 0, time.UTC)
+	noise := []float64{0.4, -0.3, 0.2, -0.5, 0.1, 0.3, -0.2, -0.4, 0.5, -0.1}
+	var rising, flat []timePoint
+	for i, n := range noise {
+		at := start.Add(time.Duration(i) * time.Hour)
+		rising = append(rising, timePoint{T: at, V: 100 + 5*float64(i) + n})
+		flat = append(flat, timePoint{T: at, V: 100 + 6*n})
+	}
+	opts := options{leakSlope: 1, leakR2: 0.8}
+
+	slope, r2 := memoryTrend(rising)
+	if math.Abs(slope-5) > 0.2 || r2 < 0.99 {
+		t.Errorf("rising series: slope %.3f MB/h, R² %.3f; want ~5 and ~1", slope, r2)
+	}
+	if !isLeaking(slope, r2, opts) {
+		t.Error("rising series not flagged as leaking")
+	}
+
+	slope, r2 = memoryTrend(flat)
+	if isLeaking(slope, r2, opts) {
+		t.Errorf("flat noisy series flagged as leaking (slope %.3f MB/h, R² %.3f)", slope, r2)
+	}
+}
+
+func TestMemoryTrendDegenerate(t *testing.T) {
+	at := time.Date(2025, 2, 21, 0, 0, 0, 0, time.UTC)
+	if slope, r2 := memoryTrend([]timePoint{{T: at, V: 1}}); slope != 0 || r2 != 0 {
+		t.Errorf("one sample: slope %v, R² %v; want 0, 0", slope, r2)
+	}
+	if slope, r2 := memoryTrend([]timePoint{{T: at, V: 1}, {T: at, V: 2}}); slope != 0 || r2 != 0 {
+		t.Errorf("same timestamp: slope %v, R² %v; want 0, 0", slope, r2)
+	}
+}
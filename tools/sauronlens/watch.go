@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+// ansiClear moves the cursor home and clears the screen.
+const ansiClear = "\x1b[H\x1b[2J"
+
+// watch re-reads paths from scratch every interval, clears the screen and
+// redraws the report, until the process receives SIGINT or SIGTERM. It
+// returns the exit code of the last report.
+func watch(paths []string, interval time.Duration, opts options, strict bool) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	code := 0
+	for {
+		stats, err := reaggregate(paths, &opts, strict)
+		_, _ = fmt.Fprint(opts.out, ansiClear)
+		if err != nil {
+			fmt.Println("Error processing logs:", err)
+		} else {
+			_, _ = fmt.Fprintf(opts.out, "=== %s (every %s) ===\n", time.Now().Format("2006-01-02 15:04:05"), interval)
+			code = report(stats, opts)
+		}
+		select {
+		case <-ctx.Done():
+			return code
+		case <-ticker.C:
+		}
+	}
+}
+
+// reaggregate aggregates paths into a fresh stats map, resetting the
+// per-run state in opts so nothing carries over from the previous cycle.
+func reaggregate(paths []string, opts *options, strict bool) (map[string]parse.ProcessStats, error) {
+	opts.skips = &skipReport{}
+	if opts.coverage != nil {
+		opts.coverage = newFieldCoverage(opts.coverage.format)
+	}
+	if opts.reorder != nil {
+		opts.reorder = &reorderBuffer{window: opts.reorder.window}
+	}
+	return processFiles(paths, *opts, strict)
+}
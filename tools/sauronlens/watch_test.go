@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alexekdahl/sauron/tools/sauronlens/parse"
+)
+
+func TestReaggregateStartsFresh(t *testing.T) {
+	dir := t.TempDir()
+	path := writeLog(t, dir, "process.log",
+		logLine(1, "old", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		"garbage",
+	)
+	opts := testOptions()
+	opts.coverage = newFieldCoverage(parse.DefaultFormat)
+
+	first, err := reaggregate([]string{path}, &opts, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first["old"].Count != 1 || opts.skips.Skipped != 1 {
+		t.Fatalf("first cycle: old count %d, %d skipped; want 1 and 1", first["old"].Count, opts.skips.Skipped)
+	}
+
+	// The log is overwritten rather than appended to.
+	writeLog(t, dir, "process.log", logLine(2, "new", "Running", 20, 1, "2025-02-21T12:01:00Z"))
+	second, err := reaggregate([]string{path}, &opts, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sortedNames(second, sortName); !slices.Equal(got, []string{"new"}) || second["new"].Count != 1 {
+		t.Errorf("second cycle: processes %v, want only new with one sample", got)
+	}
+	if opts.skips.Skipped != 0 || opts.coverage.lines != 1 {
+		t.Errorf("second cycle: %d skipped, %d coverage lines; want 0 and 1", opts.skips.Skipped, opts.coverage.lines)
+	}
+	if first["old"].Count != 1 {
+		t.Error("the second cycle modified the first cycle's map")
+	}
+}
+
+func TestReaggregateResetsReorderCounts(t *testing.T) {
+	path := writeLog(t, t.TempDir(), "process.log",
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:01:00Z"),
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T12:00:00Z"),
+		logLine(1, "foo", "Running", 10, 1, "2025-02-21T11:00:00Z"),
+	)
+	opts := testOptions()
+	opts.reorder = &reorderBuffer{window: 5 * time.Minute}
+	for cycle := 1; cycle <= 2; cycle++ {
+		if _, err := reaggregate([]string{path}, &opts, true); err != nil {
+			t.Fatal(err)
+		}
+		if opts.reorder.OutOfOrder != 2 || opts.reorder.TooLate != 1 {
+			t.Errorf("cycle %d: %d out of order, %d too late; want 2 and 1", cycle, opts.reorder.OutOfOrder, opts.reorder.TooLate)
+		}
+	}
+}